@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"internal/bytecode"
+	"internal/diagnostic"
+	"internal/util/log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compileFile compiles a source file to a .hoc file. Without -o the output
+// sits next to the source with its extension replaced.
+func compileFile(fileName, out string) {
+	ch := compileSource(fileName, readFile(fileName))
+
+	data, err := bytecode.Encode(ch)
+	if err != nil {
+		log.Fatal("Encode error", log.S("file", fileName), log.E(err))
+	}
+
+	if out == "" {
+		out = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".hoc"
+	}
+
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		log.Fatal("Write file error", log.S("file", out), log.E(err))
+	}
+
+	log.Info("Compiled", log.S("file", fileName), log.S("out", out), log.I("bytes", len(data)))
+}
+
+// compileSource compiles a source file to bytecode, exiting after the
+// errors are reported if it does not compile.
+func compileSource(fileName string, source []byte) *bytecode.Chunk {
+	statements, ok := parse(fileName, source)
+	if !ok {
+		log.Debug("Compile failed", log.S("file", fileName))
+		exit(exitCompileError)
+	}
+
+	ch, err := compile(statements)
+	if err != nil {
+		reportError(diagnostic.NewSource(fileName, string(source)), err)
+		log.Debug("Compile failed", log.S("file", fileName), log.E(err))
+		exit(exitCompileError)
+	}
+
+	return ch
+}
+
+// execFile runs a .hoc file, refusing anything that is not compiled bytecode.
+func execFile(fileName string, eng engine, gc gcOptions) {
+	fileBody := readFile(fileName)
+
+	if !bytecode.IsCompiled(fileBody) {
+		log.Fatal("Not a compiled .hoc file", log.S("file", fileName))
+	}
+
+	runCompiled(fileName, fileBody, eng, gc)
+}
+
+// runCompiled verifies and runs precompiled bytecode. There is no AST to
+// give the tree-walker, so it always runs on the VM.
+func runCompiled(fileName string, data []byte, eng engine, gc gcOptions) {
+	if eng == engineBoth {
+		log.Fatal("Cannot compare engines on a compiled file", log.S("file", fileName))
+	}
+
+	ch, err := bytecode.Decode(data)
+	if err != nil {
+		log.Fatal("Load compiled file error", log.S("file", fileName), log.E(err))
+	}
+
+	// The source is not shipped with bytecode, so diagnostics show positions only.
+	vm := gc.newVM()
+	vm.SetLoader(newModuleLoader(fileName).vm)
+	err = vmResult(vm, vm.InterpretDecoded(ch))
+	reportError(diagnostic.NewSource(fileName, ""), err)
+	gc.report(vm)
+
+	if err != nil {
+		exit(exitStatus(err))
+	}
+}
+
+// disasmFile prints the bytecode for a source or .hoc file, including every
+// nested function.
+func disasmFile(fileName string) {
+	fileBody := readFile(fileName)
+
+	var ch *bytecode.Chunk
+	var err error
+
+	if bytecode.IsCompiled(fileBody) {
+		ch, err = bytecode.Decode(fileBody)
+		if err != nil {
+			log.Fatal("Load compiled file error", log.S("file", fileName), log.E(err))
+		}
+	} else {
+		ch = compileSource(fileName, fileBody)
+	}
+
+	for _, line := range ch.DisassembleAll("<script>") {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// engine selects which backend executes a program.
+type engine string
+
+const (
+	engineTree engine = "tree" // tree-walking interpreter (HoLang1)
+	engineVM   engine = "vm"   // bytecode compiler + VM
+	engineBoth engine = "both" // run both and compare their output
+)
+
+func parseEngine(name string) (engine, bool) {
+	switch e := engine(name); e {
+	case engineTree, engineVM, engineBoth:
+		return e, true
+	}
+
+	return "", false
+}
+
+// engineResult is what one backend produced for a differential run.
+type engineResult struct {
+	output string
+	err    error
+}
+
+// reportDivergence compares the tree-walker and VM results line by line and
+// writes the first difference to w. It returns true if the engines diverged.
+func reportDivergence(w io.Writer, tree, vm engineResult) bool {
+	treeLines := strings.Split(tree.output, "\n")
+	vmLines := strings.Split(vm.output, "\n")
+
+	for i := 0; i < max(len(treeLines), len(vmLines)); i++ {
+		var treeLine, vmLine string
+		treeOk, vmOk := i < len(treeLines), i < len(vmLines)
+
+		if treeOk {
+			treeLine = treeLines[i]
+		}
+
+		if vmOk {
+			vmLine = vmLines[i]
+		}
+
+		if treeOk == vmOk && treeLine == vmLine {
+			continue
+		}
+
+		fmt.Fprintf(w, "engine divergence at output line %d\n", i+1)
+		fmt.Fprintf(w, "  tree: %s\n", describeLine(treeLine, treeOk))
+		fmt.Fprintf(w, "  vm:   %s\n", describeLine(vmLine, vmOk))
+
+		return true
+	}
+
+	if (tree.err == nil) != (vm.err == nil) {
+		fmt.Fprintln(w, "engine divergence in result")
+		fmt.Fprintf(w, "  tree: %s\n", describeErr(tree.err))
+		fmt.Fprintf(w, "  vm:   %s\n", describeErr(vm.err))
+
+		return true
+	}
+
+	return false
+}
+
+func describeLine(line string, ok bool) string {
+	if !ok {
+		return "<no output>"
+	}
+
+	return fmt.Sprintf("%q", line)
+}
+
+func describeErr(err error) string {
+	if err == nil {
+		return "ok"
+	}
+
+	return "error: " + err.Error()
+}
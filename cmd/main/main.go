@@ -1,36 +1,109 @@
 package main
 
 import (
+	"internal/builtin"
 	"internal/util/log"
 	"os"
+	"strings"
 )
 
+const usage = "Usage: holang [--debug] [--engine=tree|vm|both] [--gc-stress] [--gc-log] [--allow-read=dir] [--allow-write=dir] [file [args...] | compile file [-o out.hoc] | exec file.hoc [args...] | disasm file]"
+
 func main() {
-	// Simple arg parsing: --debug optional + optional file
+	// Simple arg parsing: --debug, --engine=<name>, --gc-* optional + optional file.
+	// Options come first; everything from the first other argument on is the
+	// command, and whatever follows the script is passed to it as os.args.
 	args := os.Args[1:]
 	var fileName string
+	var gc gcOptions
+	eng := engineTree
 
-	filtered := make([]string, 0, len(args))
-	for _, a := range args {
+	var filtered []string
+	for i, a := range args {
 		if a == "--debug" {
 			log.EnableDebug()
 			continue
 		}
-		filtered = append(filtered, a)
+
+		if a == "--gc-stress" {
+			gc.stress = true
+			continue
+		}
+
+		if a == "--gc-log" {
+			gc.log = true
+			continue
+		}
+
+		if dir, ok := strings.CutPrefix(a, "--allow-read="); ok {
+			if err := builtin.AllowRead(dir); err != nil {
+				log.Fatal("Invalid --allow-read directory", log.S("dir", dir), log.E(err))
+			}
+			continue
+		}
+
+		if dir, ok := strings.CutPrefix(a, "--allow-write="); ok {
+			if err := builtin.AllowWrite(dir); err != nil {
+				log.Fatal("Invalid --allow-write directory", log.S("dir", dir), log.E(err))
+			}
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(a, "--engine="); ok {
+			e, ok := parseEngine(name)
+			if !ok {
+				log.Fatal(usage, log.S("engine", name))
+				return
+			}
+
+			eng = e
+			continue
+		}
+
+		filtered = args[i:]
+		break
 	}
 
-	if len(filtered) > 1 { // too many non-flag args
-		log.Fatal("Usage: holang [--debug] [file]", log.A("args", os.Args))
-		return
+	builtin.SetExit(exit)
+
+	if len(filtered) > 0 {
+		switch filtered[0] {
+		case "compile": // compile <file> [-o <out>]
+			switch {
+			case len(filtered) == 2:
+				compileFile(filtered[1], "")
+			case len(filtered) == 4 && filtered[2] == "-o":
+				compileFile(filtered[1], filtered[3])
+			default:
+				log.Fatal(usage, log.A("args", os.Args))
+			}
+			return
+
+		case "exec": // exec <file.hoc>
+			if len(filtered) < 2 {
+				log.Fatal(usage, log.A("args", os.Args))
+			}
+			builtin.SetArgs(filtered[2:])
+			execFile(filtered[1], eng, gc)
+			return
+
+		case "disasm": // disasm <file>
+			if len(filtered) != 2 {
+				log.Fatal(usage, log.A("args", os.Args))
+			}
+			disasmFile(filtered[1])
+			return
+		}
 	}
 
-	if len(filtered) == 1 {
+	if len(filtered) > 0 {
 		fileName = filtered[0]
-		log.Info("HOLANG with file", log.S("file", fileName))
-		runFile(fileName)
+		builtin.SetArgs(filtered[1:])
+		log.Info("HOLANG with file", log.S("file", fileName), log.S("engine", string(eng)))
+		runFile(fileName, eng, gc)
 		return
 	}
 
-	log.Info("HOLANG Loop Start")
-	runLoop()
+	log.Info("HOLANG Loop Start", log.S("engine", string(eng)))
+	runLoop(eng, gc)
 }
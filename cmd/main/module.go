@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"internal/ast"
+	"internal/bytecode"
+	"internal/diagnostic"
+	"os"
+	"path/filepath"
+)
+
+// errModuleInvalid is wrapped by the import error for a script that does
+// not compile, so the run exits as if the script being run had not.
+var errModuleInvalid = errors.New("has errors")
+
+// moduleSources keeps the text of every imported script, so an error inside
+// one is quoted from the right file.
+var moduleSources = make(map[string]*diagnostic.Source)
+
+// moduleLoader finds, parses and compiles the scripts a program imports. A
+// file is loaded once and shared by both engines, so its compile errors are
+// reported once even when the engines are compared.
+type moduleLoader struct {
+	main   string // the script being run
+	loaded map[string]*loadedModule
+}
+
+type loadedModule struct {
+	program []ast.Stmt
+	chunk   *bytecode.Chunk
+	err     error
+}
+
+func newModuleLoader(main string) *moduleLoader {
+	return &moduleLoader{
+		main:   main,
+		loaded: make(map[string]*loadedModule),
+	}
+}
+
+// tree and vm are the loaders of the two engines.
+func (l *moduleLoader) tree(path string, from string) (string, []ast.Stmt, error) {
+	file, module, err := l.load(path, from)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return file, module.program, nil
+}
+
+func (l *moduleLoader) vm(path string, from string) (string, *bytecode.Chunk, error) {
+	file, module, err := l.load(path, from)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return file, module.chunk, nil
+}
+
+func (l *moduleLoader) load(path string, from string) (string, *loadedModule, error) {
+	file, err := l.find(path, from)
+	if err != nil {
+		return "", nil, err
+	}
+
+	module, ok := l.loaded[file]
+	if !ok {
+		module = l.compile(path, file)
+		l.loaded[file] = module
+	}
+
+	return file, module, module.err
+}
+
+// find resolves path against the directory of from, or of the script being
+// run if from is empty, and then against each directory in HOLANG_PATH. The
+// .holang extension may be left off.
+func (l *moduleLoader) find(path string, from string) (string, error) {
+	if from == "" {
+		from = l.main
+	}
+
+	dirs := []string{filepath.Dir(from)}
+	if filepath.IsAbs(path) {
+		dirs = []string{""}
+	} else {
+		dirs = append(dirs, filepath.SplitList(os.Getenv("HOLANG_PATH"))...)
+	}
+
+	for _, dir := range dirs {
+		for _, candidate := range []string{path, path + ".holang"} {
+			file := filepath.Join(dir, candidate)
+
+			if info, err := os.Stat(file); err != nil || info.IsDir() {
+				continue
+			}
+
+			// the script being run may also be named without its extension
+			if sameFile(file, l.main) || sameFile(file, l.main+".holang") {
+				return "", fmt.Errorf("cannot import %q: it is the script being run", path)
+			}
+
+			return file, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot find module %q", path)
+}
+
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(aInfo, bInfo)
+}
+
+// compile parses and compiles file, reporting its errors against its own
+// source.
+func (l *moduleLoader) compile(path string, file string) *loadedModule {
+	text, err := os.ReadFile(file)
+	if err != nil {
+		return &loadedModule{err: fmt.Errorf("cannot import %q: %w", path, err)}
+	}
+
+	source := diagnostic.NewSource(file, string(text))
+	moduleSources[file] = source
+
+	failed := &loadedModule{err: fmt.Errorf("cannot import %q: %s %w", path, file, errModuleInvalid)}
+
+	program, ok := parse(file, text)
+	if !ok {
+		return failed
+	}
+
+	chunk, err := compile(program)
+	if err != nil {
+		reportError(source, err)
+
+		return failed
+	}
+
+	return &loadedModule{program: program, chunk: chunk}
+}
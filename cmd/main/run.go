@@ -2,19 +2,153 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"internal/ast"
 	"internal/bytecode"
 	"internal/codegen"
+	"internal/diagnostic"
 	interpreter_ "internal/interpreter"
 	"internal/parser"
 	"internal/scanner"
 	"internal/util/log"
 	vm_ "internal/vm"
+	"io"
 	"os"
+	"slices"
 )
 
-func runFile(fileName string) {
+var errVMRuntime = errors.New("vm runtime error")
+
+// Exit statuses for a script that fails, the ones clox uses from sysexits.h:
+// EX_DATAERR when it does not compile and EX_SOFTWARE when it fails while
+// running. Bad command lines and unreadable files exit with 1, and
+// --engine=both exits with exitDiverged when the engines disagree, whatever
+// the script did.
+const (
+	exitCompileError = 65
+	exitRuntimeError = 70
+	exitDiverged     = 3
+)
+
+// exit ends the process with status once the log is flushed. os.exit in a
+// script ends it the same way.
+func exit(status int) {
+	_ = log.Sync()
+	os.Exit(status)
+}
+
+// exitStatus is the status for the error a run ended with, or 0 if it
+// succeeded. Importing a script that does not compile is a compile error.
+func exitStatus(err error) int {
+	if errors.Is(err, errModuleInvalid) {
+		return exitCompileError
+	}
+
+	switch err.(type) {
+	case nil:
+		return 0
+	case *interpreter_.RuntimeError, *vm_.RuntimeError:
+		return exitRuntimeError
+	}
+
+	if errors.Is(err, errVMRuntime) {
+		return exitRuntimeError
+	}
+
+	return exitCompileError
+}
+
+// gcOptions configures the VM's garbage collector from the command line.
+type gcOptions struct {
+	stress bool // collect before every allocation
+	log    bool // log each collection and the totals at exit
+}
+
+func (o gcOptions) newVM() *vm_.VM {
+	vm := vm_.NewVM()
+	vm.SetGCStress(o.stress)
+	vm.SetGCLog(o.log)
+
+	return vm
+}
+
+// reportError renders err against source on stderr, quoting the offending
+// line and, for runtime errors, the HOLang stack trace. An error inside an
+// imported module is quoted from that module. An error without a
+// diagnostic is printed as it is.
+func reportError(source *diagnostic.Source, err error) {
+	if err == nil {
+		return
+	}
+
+	reportable, ok := err.(diagnostic.Reportable)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return
+	}
+
+	d := reportable.Diagnostic()
+	if module, ok := moduleSources[d.File]; ok {
+		// frames of the script being run have no file of their own
+		d.Trace = slices.Clone(d.Trace)
+		for i := range d.Trace {
+			if d.Trace[i].File == "" {
+				d.Trace[i].File = source.Name
+			}
+		}
+
+		source = module
+	}
+
+	source.Render(os.Stderr, d)
+}
+
+func (o gcOptions) report(vm *vm_.VM) {
+	if !o.log {
+		return
+	}
+
+	stats := vm.GCStats()
+	log.Info("GC stats",
+		log.I("collections", stats.Collections),
+		log.I("allocated", stats.ObjectsAllocated),
+		log.I("freed", stats.ObjectsFreed),
+		log.I("live", stats.LiveObjects),
+		log.I("bytes", stats.BytesAllocated),
+		log.I("nextGC", stats.NextGC),
+	)
+}
+
+func runFile(fileName string, eng engine, gc gcOptions) {
+	fileBody := readFile(fileName)
+
+	if bytecode.IsCompiled(fileBody) {
+		runCompiled(fileName, fileBody, eng, gc)
+		return
+	}
+
+	interpreter := interpreter_.NewInterpreter()
+	vm := gc.newVM()
+	setLoader(newModuleLoader(fileName), interpreter, vm)
+
+	status := run(fileName, fileBody, eng, interpreter, vm)
+	gc.report(vm)
+
+	if status != 0 {
+		exit(status)
+	}
+}
+
+// setLoader lets both engines import scripts through loader.
+func setLoader(loader *moduleLoader, interpreter *interpreter_.Interpreter, vm *vm_.VM) {
+	interpreter.SetLoader(loader.tree)
+	vm.SetLoader(loader.vm)
+}
+
+// readFile reads a script, trying the .holang extension if fileName has none.
+func readFile(fileName string) []byte {
 	fileBody, err := os.ReadFile(fileName)
 	if err != nil {
 		fileBody, err = os.ReadFile(fileName + ".holang")
@@ -23,28 +157,71 @@ func runFile(fileName string) {
 		}
 	}
 
-	run(fileBody, nil, nil)
+	return fileBody
 }
 
-func runLoop() {
+func runLoop(eng engine, gc gcOptions) {
 	inputScanner := bufio.NewScanner(os.Stdin)
 	interpreter := interpreter_.NewInterpreter()
-	vm := vm_.NewVM()
+	vm := gc.newVM()
+	setLoader(newModuleLoader("<stdin>"), interpreter, vm)
 
 	log.StdOut("> ")
 	for inputScanner.Scan() {
 		line := inputScanner.Bytes()
-		run(line, interpreter, vm)
+		run("<stdin>", line, eng, interpreter, vm)
 		log.StdOut("> ")
 	}
 
 	if err := inputScanner.Err(); err != nil {
 		log.Fatal("Scanner error", log.E(err))
 	}
+
+	gc.report(vm)
+}
+
+// run executes source on the chosen engine(s); name identifies it in error
+// reports. It returns the exit status for how the run ended.
+func run(name string, source []byte, eng engine, interpreter *interpreter_.Interpreter, vm *vm_.VM) int {
+	statements, ok := parse(name, source)
+	if !ok {
+		return exitCompileError
+	}
+
+	if interpreter == nil {
+		interpreter = interpreter_.NewInterpreter()
+	}
+
+	if vm == nil {
+		vm = vm_.NewVM()
+	}
+
+	var err error
+	var diverged bool
+
+	switch eng {
+	case engineTree:
+		err = runTree(statements, interpreter)
+	case engineVM:
+		err = runVM(statements, vm)
+	case engineBoth:
+		diverged, err = runBoth(statements, interpreter, vm)
+	}
+
+	reportError(diagnostic.NewSource(name, string(source)), err)
+
+	if diverged {
+		return exitDiverged
+	}
+
+	return exitStatus(err)
 }
 
-func run(source []byte, interpreter *interpreter_.Interpreter, vm *vm_.VM) {
+// parse scans and parses source, rendering any errors against name, and
+// reports whether it was free of errors.
+func parse(name string, source []byte) ([]ast.Stmt, bool) {
 	sourceStr := string(source)
+	diagSource := diagnostic.NewSource(name, sourceStr)
 
 	log.InfoIfEnabled("Run source", func() []log.Field {
 		_sourceStr := sourceStr
@@ -65,7 +242,11 @@ func run(source []byte, interpreter *interpreter_.Interpreter, vm *vm_.VM) {
 	log.Debug("Scan complete", log.A("tokens", tokens), log.A("errors", errs))
 
 	if len(errs) > 0 {
-		return
+		for _, err := range errs {
+			reportError(diagSource, err)
+		}
+
+		return nil, false
 	}
 
 	// ================================================================
@@ -83,54 +264,120 @@ func run(source []byte, interpreter *interpreter_.Interpreter, vm *vm_.VM) {
 	}
 
 	if len(errs) > 0 {
-		return
-	}
+		for _, err := range errs {
+			reportError(diagSource, err)
+		}
 
-	// ================================================================
-	// Resolve + Interpret (HoLang1)
-	// ================================================================
-	if interpreter == nil {
-		interpreter = interpreter_.NewInterpreter()
+		return nil, false
 	}
 
+	return statements, true
+}
+
+// runBoth executes the program on the tree-walker and then on the VM.
+// The tree-walker talks to the terminal as usual while its output and the
+// input it consumed are recorded; the VM gets the same input replayed and
+// its output is only captured, so side effects are visible once. It
+// reports whether the engines diverged, and returns the tree-walker's error
+// for reporting.
+func runBoth(statements []ast.Stmt, interpreter *interpreter_.Interpreter, vm *vm_.VM) (bool, error) {
+	var treeOut, vmOut, input bytes.Buffer
+
+	in := interpreter.Stdin()
+
+	interpreter.SetOutput(io.MultiWriter(os.Stdout, &treeOut))
+	interpreter.SetInput(io.TeeReader(in, &input))
+	treeErr := runTree(statements, interpreter)
+	interpreter.SetOutput(os.Stdout)
+
+	// input() reads ahead, so what the tree-walker read but did not use goes
+	// back in front of the rest of the input, and the VM gets only what it used
+	ahead := interpreter.Stdin()
+	unread, _ := ahead.Peek(ahead.Buffered())
+	used := input.Bytes()[:input.Len()-len(unread)]
+	interpreter.SetInput(io.MultiReader(bytes.NewReader(unread), in))
+
+	vm.SetOutput(&vmOut)
+	vm.SetInput(bytes.NewReader(used))
+	vmErr := runVM(statements, vm)
+	vm.SetOutput(os.Stdout)
+	vm.SetInput(os.Stdin)
+
+	diverged := reportDivergence(
+		os.Stderr,
+		engineResult{output: treeOut.String(), err: treeErr},
+		engineResult{output: vmOut.String(), err: vmErr},
+	)
+
+	log.Debug("Differential run complete", log.A("diverged", diverged))
+
+	return diverged, treeErr
+}
+
+// ================================================================
+// Resolve + Interpret (HoLang1)
+// ================================================================
+func runTree(statements []ast.Stmt, interpreter *interpreter_.Interpreter) error {
 	resolver := interpreter_.NewResolver(interpreter)
 	err := resolver.Resolve(statements)
 
 	log.Debug("Resolve complete", log.E(err))
 
-	if err == nil {
-		err = interpreter.Interpret(statements)
+	if err != nil {
+		return err
+	}
 
-		log.Debug("Interpret complete", log.E(err))
-	} else {
-		log.Error("Resolve error", log.E(err))
+	err = interpreter.Interpret(statements)
+
+	log.Debug("Interpret complete", log.E(err))
+
+	return err
+}
+
+// ================================================================
+// Codegen + Run (HoLang2)
+// ================================================================
+func runVM(statements []ast.Stmt, vm *vm_.VM) error {
+	ch, err := compile(statements)
+	if err != nil {
+		return err
 	}
 
-	// ================================================================
-	// Codegen
-	// ================================================================
+	return execChunk(ch, vm)
+}
 
+func compile(statements []ast.Stmt) (*bytecode.Chunk, error) {
 	ch := bytecode.NewChunk()
 	em := codegen.NewChunkEmitter(ch)
 	gen := codegen.NewCodeGenerator(em)
 
 	if err := gen.Generate(statements); err != nil {
-		log.Error("Codegen error", log.E(err))
+		log.Debug("Codegen error", log.E(err))
 
-		return
+		return nil, err
 	}
 
 	disassemble := ch.Disassemble()
 	log.Debug("Codegen complete", log.A("bytecode", disassemble))
 
-	// ================================================================
-	// Run
-	// ================================================================
-	if vm == nil {
-		vm = vm_.NewVM()
-	}
-	result := vm.Interpret(ch)
+	return ch, nil
+}
+
+func execChunk(ch *bytecode.Chunk, vm *vm_.VM) error {
+	return vmResult(vm, vm.Interpret(ch))
+}
+
+// vmResult turns how the VM finished into the error to report, if any.
+func vmResult(vm *vm_.VM, result vm_.InterpretResult) error {
+	log.Debug("VM interpret finished", log.A("result", result))
 
-	log.Info("VM interpret finished", log.A("result", result))
+	if result != vm_.InterpretResultOK {
+		if err := vm.LastError(); err != nil {
+			return err
+		}
+
+		return errVMRuntime
+	}
 
+	return nil
 }
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"internal/builtin"
+	interpreter_ "internal/interpreter"
+	vm_ "internal/vm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runEngine runs source on one engine, as holang --engine=tree or
+// --engine=vm --gc-stress would, and returns what it printed and the message
+// of the error it ended with. The script and files, such as modules it
+// imports, are written to a directory of their own, whose path is left out
+// of the error. The script runs in that directory and may read it.
+func runEngine(t *testing.T, eng engine, source string, files map[string]string) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Chdir(dir)
+	if err := builtin.AllowRead(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	main := filepath.Join(dir, "main.holang")
+	if err := os.WriteFile(main, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statements, ok := parse(main, []byte(source))
+	if !ok {
+		t.Fatalf("%q does not parse", source)
+	}
+
+	var out bytes.Buffer
+	var err error
+
+	interpreter := interpreter_.NewInterpreter()
+	vm := gcOptions{stress: true}.newVM()
+	setLoader(newModuleLoader(main), interpreter, vm)
+
+	switch eng {
+	case engineTree:
+		interpreter.SetOutput(&out)
+		err = runTree(statements, interpreter)
+	case engineVM:
+		vm.SetOutput(&out)
+		err = runVM(statements, vm)
+	}
+
+	if err != nil {
+		return out.String(), strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), "")
+	}
+
+	return out.String(), ""
+}
+
+// TestEngines runs each program on the tree-walker and on the VM and checks
+// that both print the same and end with the same error, as --engine=both
+// expects.
+func TestEngines(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "try catch finally",
+			source: `
+				fun risky(n) {
+					if (n > 1) throw "too big";
+					return n;
+				}
+				for (var i = 0; i < 5; i = i + 1) {
+					try {
+						print risky(i);
+					} catch (e) {
+						print e;
+						break;
+					} finally {
+						print "finally";
+					}
+				}
+				try { var x = 1 + nil; } catch (e) { print e.message; print e.line; }
+				fun f() { try { return "try"; } finally { print "cleanup"; } }
+				print f();
+				try { try { throw 1; } finally { throw 2; } } catch (e) { print e; }
+			`,
+			want: []string{"0", "finally", "1", "finally", "too big", "finally",
+				"operand must be a int or float", "16", "cleanup", "try", "2"},
+		},
+		{
+			name: "finally runs on continue, break and nested rethrow",
+			source: `
+				fun walk() {
+					for (var i = 0; i < 5; i = i + 1) {
+						try {
+							if (i == 1) continue;
+							if (i == 3) break;
+							print i;
+						} finally {
+							print "left";
+						}
+					}
+					return "walked";
+				}
+				print walk();
+				fun inner() {
+					try { throw "deep"; } catch (e) { print "inner " + e; throw e + "!"; } finally { print "inner finally"; }
+				}
+				try { inner(); } catch (e) { print e; }
+			`,
+			want: []string{"0", "left", "left", "2", "left", "left", "walked",
+				"inner deep", "inner finally", "deep!"},
+		},
+		{
+			name:    "uncaught throw runs finally first",
+			source:  "fun f() { throw \"boom\"; }\ntry { f(); } finally { print 1; }\n",
+			want:    []string{"1"},
+			wantErr: "uncaught exception: boom",
+		},
+		{
+			name: "lists and callbacks",
+			source: `
+				class Box { init(v) { this.v = v; } }
+				fun box(x) { return Box(x * 10); }
+				fun notTwenty(b) { return b.v / 10 != 2; }
+				fun value(b) { return b.v; }
+				fun fail(x) { throw "bad " + str(x); }
+				var xs = [3, 1, 2];
+				xs.push(-4);
+				xs[0] = xs[-1];
+				xs.sort();
+				print xs;
+				print xs.map(box).filter(notTwenty).map(value).join("+");
+				try { xs.map(fail); } catch (e) { print e; }
+				try { print xs[9]; } catch (e) { print e.message; }
+			`,
+			want: []string{"[-4, -4, 1, 2]", "-40+-40+10", "bad -4", "list index out of range: 9"},
+		},
+		{
+			name: "maps keep insertion order",
+			source: `
+				var m = {"hp": 10, "atk": 3};
+				m["def"] = 1;
+				m["hp"] = m["hp"] + 1;
+				m.delete("atk");
+				m[2.0] = "two";
+				print m;
+				print m.keys();
+				print m[2] + str(m.has("atk"));
+			`,
+			want: []string{`{"hp": 11, "def": 1, 2: "two"}`, `["hp", "def", 2]`, "twofalse"},
+		},
+		{
+			name: "for-in loops",
+			source: `
+				class Twice {
+					init(v) { this.v = v; this.n = 0; }
+					iter() { return this; }
+					next() {
+						if (this.n == 2) return nil;
+						this.n = this.n + 1;
+						return this.v;
+					}
+				}
+				var out = [];
+				for (var x in [1, 2]) out.push(x);
+				for (var k in {"a": 1}) out.push(k);
+				for (var c in "hé") out.push(c);
+				for (var i in range(10, 0, -4)) {
+					if (i == 6) continue;
+					out.push(i);
+				}
+				for (var t in Twice("t")) out.push(t);
+				var fs = [];
+				for (var i in range(0, 3, 1)) { fun f() { return i; } fs.push(f); }
+				for (var f in fs) { if (f() == 2) break; out.push(f()); }
+				print out.join(" ");
+				for (var x in 3) print x;
+			`,
+			want:    []string{"1 2 a h é 10 2 t t 0 1"},
+			wantErr: "a number is not iterable",
+		},
+		{
+			name: "finally runs when a for-in loop is left",
+			source: `
+				fun walk() {
+					for (var i in range(0, 5, 1)) {
+						try {
+							if (i == 1) continue;
+							if (i == 3) break;
+							print i;
+						} finally {
+							print "left " + str(i);
+						}
+					}
+					return "walked";
+				}
+				print walk();
+			`,
+			want: []string{"0", "left 0", "left 1", "2", "left 2", "left 3", "walked"},
+		},
+		{
+			name: "lambdas",
+			source: `
+				fun counter() {
+					var n = 0;
+					return () => n = n + 1;
+				}
+				var c = counter();
+				c();
+				var add = fun (a, b) { return a + b; };
+				var odd = [1, 2, 3].filter((x) => x != 2);
+				print str(c()) + " " + str(add(1, 2)) + " " + odd.join(",") + " " + str((1 + 2) * 3);
+				class Box { init(v) { this.v = v; } }
+				print [3, 1, 2].map((x) => Box(x * 10)).filter((b) => b.v != 10).map((b) => b.v);
+			`,
+			want: []string{"2 3 1,3 9", "[30, 20]"},
+		},
+		{
+			name: "modules run once with their own globals",
+			source: `
+				import "counter" as c;
+				from "counter" import bump;
+				var n = 100;
+				bump();
+				print c.bump() + n;
+				print c.n;
+			`,
+			files: map[string]string{
+				"counter.holang": `
+					var n = 0;
+					fun bump() { n = n + 1; return n; }
+					print "loaded";
+				`,
+			},
+			want: []string{"loaded", "102", "2"},
+		},
+		{
+			name:    "import cycle",
+			source:  `import "a";`,
+			files:   map[string]string{"a.holang": `import "b";`, "b.holang": `import "a";`},
+			wantErr: "import cycle: a.holang -> b.holang -> a.holang",
+		},
+		{
+			name: "for-in over a file visits its lines",
+			source: `
+				import "fs";
+				var f = fs.open("map.txt");
+				print f.readLine();
+				for (var line in f) print "[" + line + "]";
+				for (var line in fs.open("map.txt")) { print line; break; }
+			`,
+			files: map[string]string{"map.txt": "#\r\n.@\n\n.."},
+			want:  []string{"#", "[.@]", "[]", "[..]", "#"},
+		},
+		{
+			name: "json writes instances as objects",
+			source: `
+				import "json";
+				class Hero {
+					init(name) { this.name = name; this.hp = 10; this.bag = ["key"]; }
+					heal() { this.hp = this.hp + 1; }
+				}
+				var hero = Hero("호랭");
+				hero.heal();
+				print json.stringify({"hero": hero, "ratio": 2.0}, nil);
+				print json.stringify(hero, 1);
+				hero.bag.push(hero);
+				try { json.stringify(hero, nil); } catch (e) { print e.message; }
+				try { json.stringify(hero.heal, nil); } catch (e) { print e.message; }
+			`,
+			want: []string{
+				`{"hero":{"bag":["key"],"hp":11,"name":"호랭"},"ratio":2.0}`,
+				"{", ` "bag": [`, `  "key"`, ` ],`, ` "hp": 11,`, ` "name": "호랭"`, "}",
+				"stringify: cannot write a structure that contains itself",
+				"stringify: <fn heal> cannot be written as JSON",
+			},
+		},
+		{
+			name: "regex replace calls back into the program",
+			source: `
+				import "re";
+				var word = re.compile("(?P<name>\\pL+)(\\d)?");
+				var seen = [];
+				fun tag(m) {
+					seen.push(m.start);
+					if (m.groups[1] == nil) return m.named["name"];
+					return m.text + "!";
+				}
+				print word.replace("-- 호랑이7 cat", tag);
+				print seen;
+				print word.replace("ab cd", (m) => "<" + m.text + ">");
+				try { word.replace("ab", (m) => 1); } catch (e) { print e.message; }
+				try { word.replace("ab", fun (m) { throw "stop at " + m.text; }); } catch (e) { print e; }
+			`,
+			want: []string{"-- 호랑이7! cat", "[3, 8]", "<ab> <cd>",
+				"replace: function must return a string, not a number", "stop at ab"},
+		},
+		{
+			name: "stack overflow is catchable at the same depth",
+			source: `
+				fun r(n) { return r(n + 1); }
+				try { r(0); } catch (e) { print e.message; }
+				fun depth(n) { if (n == 0) return 0; return 1 + depth(n - 1); }
+				print depth(1022);
+				try { depth(1023); } catch (e) { print e.message; }
+			`,
+			want: []string{"stack overflow", "1022", "stack overflow"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := strings.Join(tt.want, "\n")
+			if want != "" {
+				want += "\n"
+			}
+
+			for _, eng := range []engine{engineTree, engineVM} {
+				got, gotErr := runEngine(t, eng, tt.source, tt.files)
+				if got != want || gotErr != tt.wantErr {
+					t.Errorf("%s:\ngot  %q, error %q\nwant %q, error %q", eng, got, gotErr, want, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_BothFailsWhenTheEnginesDiverge(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{source: `print 1;`, want: 0},
+		{source: `throw "same on both";`, want: exitRuntimeError},
+		// the clock reads differently on each engine
+		{source: `import "time"; print time.nanos();`, want: exitDiverged},
+		{source: `import "time"; print time.nanos(); throw "after";`, want: exitDiverged},
+	}
+
+	for _, tt := range tests {
+		if got := run("test.holang", []byte(tt.source), engineBoth, nil, nil); got != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestRun_ImportingAScriptThatDoesNotCompileIsACompileError(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"syntax.holang":  `var = 1;`,
+		"resolve.holang": `return 1;`,
+		"nested.holang":  `import "syntax";`,
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	main := filepath.Join(dir, "main.holang")
+
+	for _, module := range []string{"syntax", "resolve", "nested"} {
+		source := `import "` + module + `";`
+
+		for _, eng := range []engine{engineTree, engineVM, engineBoth} {
+			interpreter := interpreter_.NewInterpreter()
+			vm := vm_.NewVM()
+			setLoader(newModuleLoader(main), interpreter, vm)
+
+			if got := run(main, []byte(source), eng, interpreter, vm); got != exitCompileError {
+				t.Errorf("%s on %s: got status %d, want %d", source, eng, got, exitCompileError)
+			}
+		}
+	}
+}
+
+// redirect points os.Stdin at a file holding input and os.Stdout at a file
+// whose contents it returns, until the test ends.
+func redirect(t *testing.T, input string) func() string {
+	t.Helper()
+
+	dir := t.TempDir()
+	stdin := filepath.Join(dir, "stdin")
+	if err := os.WriteFile(stdin, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldIn, oldOut := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = in, out
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout = oldIn, oldOut
+		in.Close()
+		out.Close()
+	})
+
+	return func() string {
+		data, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+}
+
+func TestRun_BothKeepsInputTheTreeWalkerReadAhead(t *testing.T) {
+	output := redirect(t, "a\nb\nc\n")
+
+	interpreter := interpreter_.NewInterpreter()
+	vm := vm_.NewVM()
+
+	// one line at a time, as the REPL runs them
+	for _, source := range []string{`print input("");`, `print input("") + input("");`} {
+		if status := run("test.holang", []byte(source), engineBoth, interpreter, vm); status != 0 {
+			t.Fatalf("%s: got status %d, want 0", source, status)
+		}
+	}
+
+	if got, want := output(), "a\nbc\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
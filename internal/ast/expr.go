@@ -3,12 +3,15 @@ package ast
 import "internal/scanner"
 
 type Offset struct {
-	Line  int
-	Index int
+	Line   int
+	Column int
+	Index  int
+	Length int
 }
 type Expr interface {
 	Accept(visitor ExprVisitor) any
 	AcceptString(visitor ExprVisitor) string
+	Pos() Offset // position diagnostics for this node point at
 }
 
 type ExprVisitor interface {
@@ -17,8 +20,13 @@ type ExprVisitor interface {
 	VisitCallExpr(expr *Call) any
 	VisitGetExpr(expr *Get) any
 	VisitGroupingExpr(expr *Grouping) any
+	VisitIndexExpr(expr *Index) any
+	VisitIndexSetExpr(expr *IndexSet) any
+	VisitLambdaExpr(expr *Lambda) any
+	VisitListExpr(expr *List) any
 	VisitLiteralExpr(expr *Literal) any
 	VisitLogicalExpr(expr *Logical) any
+	VisitMapExpr(expr *Map) any
 	VisitSetExpr(expr *Set) any
 	VisitSuperExpr(expr *Super) any
 	VisitThisExpr(expr *This) any
@@ -41,6 +49,10 @@ func (a *Assign) AcceptString(visitor ExprVisitor) string {
 	return a.Accept(visitor).(string)
 }
 
+func (a *Assign) Pos() Offset {
+	return a.Offset
+}
+
 type Binary struct {
 	Left     Expr
 	Operator *scanner.Token
@@ -56,6 +68,10 @@ func (b *Binary) AcceptString(visitor ExprVisitor) string {
 	return b.Accept(visitor).(string)
 }
 
+func (b *Binary) Pos() Offset {
+	return b.Offset
+}
+
 type Call struct {
 	Callee    Expr
 	Paren     *scanner.Token
@@ -71,6 +87,10 @@ func (c *Call) AcceptString(visitor ExprVisitor) string {
 	return c.Accept(visitor).(string)
 }
 
+func (c *Call) Pos() Offset {
+	return c.Offset
+}
+
 type Get struct {
 	Object Expr
 	Name   *scanner.Token
@@ -85,6 +105,10 @@ func (g *Get) AcceptString(visitor ExprVisitor) string {
 	return g.Accept(visitor).(string)
 }
 
+func (g *Get) Pos() Offset {
+	return g.Offset
+}
+
 type Grouping struct {
 	Expression Expr
 	Offset     Offset
@@ -98,6 +122,88 @@ func (g *Grouping) AcceptString(visitor ExprVisitor) string {
 	return g.Accept(visitor).(string)
 }
 
+func (g *Grouping) Pos() Offset {
+	return g.Offset
+}
+
+// Index is a subscript, object[index].
+type Index struct {
+	Object  Expr
+	Bracket *scanner.Token
+	Index   Expr
+	Offset  Offset
+}
+
+func (i *Index) Accept(visitor ExprVisitor) any {
+	return visitor.VisitIndexExpr(i)
+}
+
+func (i *Index) AcceptString(visitor ExprVisitor) string {
+	return i.Accept(visitor).(string)
+}
+
+func (i *Index) Pos() Offset {
+	return i.Offset
+}
+
+// IndexSet is an assignment to a subscript, object[index] = value.
+type IndexSet struct {
+	Object  Expr
+	Bracket *scanner.Token
+	Index   Expr
+	Value   Expr
+	Offset  Offset
+}
+
+func (i *IndexSet) Accept(visitor ExprVisitor) any {
+	return visitor.VisitIndexSetExpr(i)
+}
+
+func (i *IndexSet) AcceptString(visitor ExprVisitor) string {
+	return i.Accept(visitor).(string)
+}
+
+func (i *IndexSet) Pos() Offset {
+	return i.Offset
+}
+
+// Lambda is a function expression, `fun (a) { ... }` or `(a) => a`. Function
+// is named "lambda"; the arrow form's body is a single return statement.
+type Lambda struct {
+	Function *Function
+	Offset   Offset
+}
+
+func (l *Lambda) Accept(visitor ExprVisitor) any {
+	return visitor.VisitLambdaExpr(l)
+}
+
+func (l *Lambda) AcceptString(visitor ExprVisitor) string {
+	return l.Accept(visitor).(string)
+}
+
+func (l *Lambda) Pos() Offset {
+	return l.Offset
+}
+
+// List is a list literal, [a, b, c].
+type List struct {
+	Elements []Expr
+	Offset   Offset
+}
+
+func (l *List) Accept(visitor ExprVisitor) any {
+	return visitor.VisitListExpr(l)
+}
+
+func (l *List) AcceptString(visitor ExprVisitor) string {
+	return l.Accept(visitor).(string)
+}
+
+func (l *List) Pos() Offset {
+	return l.Offset
+}
+
 type Literal struct {
 	Value  any
 	Offset Offset
@@ -111,6 +217,10 @@ func (l *Literal) AcceptString(visitor ExprVisitor) string {
 	return l.Accept(visitor).(string)
 }
 
+func (l *Literal) Pos() Offset {
+	return l.Offset
+}
+
 type Logical struct {
 	Left     Expr
 	Operator *scanner.Token
@@ -126,6 +236,29 @@ func (l *Logical) AcceptString(visitor ExprVisitor) string {
 	return l.Accept(visitor).(string)
 }
 
+func (l *Logical) Pos() Offset {
+	return l.Offset
+}
+
+// Map is a map literal, {key: value, ...}. Keys[i] goes with Values[i].
+type Map struct {
+	Keys   []Expr
+	Values []Expr
+	Offset Offset
+}
+
+func (m *Map) Accept(visitor ExprVisitor) any {
+	return visitor.VisitMapExpr(m)
+}
+
+func (m *Map) AcceptString(visitor ExprVisitor) string {
+	return m.Accept(visitor).(string)
+}
+
+func (m *Map) Pos() Offset {
+	return m.Offset
+}
+
 type Set struct {
 	Object Expr
 	Name   *scanner.Token
@@ -141,6 +274,10 @@ func (s *Set) AcceptString(visitor ExprVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Set) Pos() Offset {
+	return s.Offset
+}
+
 type Super struct {
 	Keyword *scanner.Token
 	Method  *scanner.Token
@@ -155,6 +292,10 @@ func (s *Super) AcceptString(visitor ExprVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Super) Pos() Offset {
+	return s.Offset
+}
+
 type This struct {
 	Keyword *scanner.Token
 	Offset  Offset
@@ -168,6 +309,10 @@ func (t *This) AcceptString(visitor ExprVisitor) string {
 	return t.Accept(visitor).(string)
 }
 
+func (t *This) Pos() Offset {
+	return t.Offset
+}
+
 type Ternary struct {
 	Left           Expr
 	FirstOperator  *scanner.Token
@@ -185,6 +330,10 @@ func (u *Ternary) AcceptString(visitor ExprVisitor) string {
 	return u.Accept(visitor).(string)
 }
 
+func (u *Ternary) Pos() Offset {
+	return u.Offset
+}
+
 type Unary struct {
 	Operator *scanner.Token
 	Right    Expr
@@ -199,6 +348,10 @@ func (u *Unary) AcceptString(visitor ExprVisitor) string {
 	return u.Accept(visitor).(string)
 }
 
+func (u *Unary) Pos() Offset {
+	return u.Offset
+}
+
 type Variable struct {
 	Name   *scanner.Token
 	Offset Offset
@@ -211,3 +364,7 @@ func (v *Variable) Accept(visitor ExprVisitor) any {
 func (v *Variable) AcceptString(visitor ExprVisitor) string {
 	return v.Accept(visitor).(string)
 }
+
+func (v *Variable) Pos() Offset {
+	return v.Offset
+}
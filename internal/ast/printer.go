@@ -46,6 +46,22 @@ func (p *AstPrinter) VisitGroupingExpr(e *Grouping) any {
 	return p.parenthesize("group", e.Expression)
 }
 
+func (p *AstPrinter) VisitIndexExpr(e *Index) any {
+	return p.parenthesize("[]", e.Object, e.Index)
+}
+
+func (p *AstPrinter) VisitIndexSetExpr(e *IndexSet) any {
+	return p.parenthesize("[]=", e.Object, e.Index, e.Value)
+}
+
+func (p *AstPrinter) VisitLambdaExpr(e *Lambda) any {
+	return p.VisitFunctionStmt(e.Function)
+}
+
+func (p *AstPrinter) VisitListExpr(e *List) any {
+	return p.parenthesize("list", e.Elements)
+}
+
 func (p *AstPrinter) VisitLiteralExpr(e *Literal) any {
 	if e.Value == nil {
 		return "nil"
@@ -57,6 +73,15 @@ func (p *AstPrinter) VisitLogicalExpr(e *Logical) any {
 	return p.parenthesize(e.Operator.Lexeme, e.Left, e.Right)
 }
 
+func (p *AstPrinter) VisitMapExpr(e *Map) any {
+	var parts []any
+	for i := range e.Keys {
+		parts = append(parts, e.Keys[i], e.Values[i])
+	}
+
+	return p.parenthesize("map", parts...)
+}
+
 func (p *AstPrinter) VisitSetExpr(e *Set) any {
 	return p.parenthesize("=", e.Object, e.Name.Lexeme, e.Value)
 }
@@ -166,7 +191,10 @@ func (p *AstPrinter) VisitVarStmt(s *Var) any {
 }
 
 func (p *AstPrinter) VisitWhileStmt(s *While) any {
-	return p.parenthesize("while", s.Condition, s.Body)
+	if s.Increment == nil {
+		return p.parenthesize("while", s.Condition, s.Body)
+	}
+	return p.parenthesize("while", s.Condition, s.Body, s.Increment)
 }
 
 func (p *AstPrinter) VisitBreakStmt(s *Break) any {
@@ -177,6 +205,41 @@ func (p *AstPrinter) VisitContinueStmt(s *Continue) any {
 	return p.parenthesize("continue")
 }
 
+func (p *AstPrinter) VisitThrowStmt(s *Throw) any {
+	return p.parenthesize("throw", s.Value)
+}
+
+func (p *AstPrinter) VisitTryStmt(s *Try) any {
+	parts := []any{s.Body}
+
+	if s.CatchBody != nil {
+		parts = append(parts, p.parenthesize("catch", s.CatchName.Lexeme, s.CatchBody))
+	}
+
+	if s.Finally != nil {
+		parts = append(parts, p.parenthesize("finally", s.Finally))
+	}
+
+	return p.parenthesize("try", parts...)
+}
+
+func (p *AstPrinter) VisitForInStmt(s *ForIn) any {
+	return p.parenthesize("for-in", s.Name.Lexeme, s.Iterable, s.Body)
+}
+
+func (p *AstPrinter) VisitImportStmt(s *Import) any {
+	if s.Alias != nil {
+		return p.parenthesize("import", s.Path.Lexeme, "as", s.Alias.Lexeme)
+	}
+
+	names := make([]string, len(s.Names))
+	for i, name := range s.Names {
+		names[i] = name.Lexeme
+	}
+
+	return p.parenthesize("from", s.Path.Lexeme, "import", strings.Join(names, " "))
+}
+
 // -----------------------------------------------------------------------------
 // print-utilities
 
@@ -5,6 +5,7 @@ import "internal/scanner"
 type Stmt interface {
 	Accept(visitor StmtVisitor) any
 	AcceptString(visitor StmtVisitor) string
+	Pos() Offset // position diagnostics for this node point at
 }
 
 type StmtVisitor interface {
@@ -19,6 +20,10 @@ type StmtVisitor interface {
 	VisitWhileStmt(stmt *While) any
 	VisitBreakStmt(stmt *Break) any
 	VisitContinueStmt(stmt *Continue) any
+	VisitThrowStmt(stmt *Throw) any
+	VisitTryStmt(stmt *Try) any
+	VisitForInStmt(stmt *ForIn) any
+	VisitImportStmt(stmt *Import) any
 }
 
 type Block struct {
@@ -34,6 +39,10 @@ func (s *Block) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Block) Pos() Offset {
+	return s.Offset
+}
+
 type Class struct {
 	Name       *scanner.Token
 	Superclass *Variable
@@ -49,6 +58,10 @@ func (c *Class) AcceptString(visitor StmtVisitor) string {
 	return c.Accept(visitor).(string)
 }
 
+func (c *Class) Pos() Offset {
+	return c.Offset
+}
+
 type Expression struct {
 	Expression Expr
 	Offset     Offset
@@ -62,6 +75,10 @@ func (s *Expression) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Expression) Pos() Offset {
+	return s.Offset
+}
+
 type Function struct {
 	Name   *scanner.Token
 	Params []*scanner.Token
@@ -77,6 +94,10 @@ func (f *Function) AcceptString(visitor StmtVisitor) string {
 	return f.Accept(visitor).(string)
 }
 
+func (f *Function) Pos() Offset {
+	return f.Offset
+}
+
 type If struct {
 	Condition  Expr
 	ThenBranch Stmt
@@ -92,6 +113,10 @@ func (s *If) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *If) Pos() Offset {
+	return s.Offset
+}
+
 type Print struct {
 	Expression Expr
 	Offset     Offset
@@ -105,6 +130,10 @@ func (s *Print) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Print) Pos() Offset {
+	return s.Offset
+}
+
 type Return struct {
 	Keyword *scanner.Token
 	Value   Expr
@@ -119,6 +148,10 @@ func (s *Return) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Return) Pos() Offset {
+	return s.Offset
+}
+
 type Var struct {
 	Name        *scanner.Token
 	Initializer Expr
@@ -133,9 +166,16 @@ func (s *Var) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Var) Pos() Offset {
+	return s.Offset
+}
+
+// While also carries the increment of a desugared for loop, so that
+// `continue` still runs it before the condition is re-checked.
 type While struct {
 	Condition Expr
 	Body      Stmt
+	Increment Expr
 	Offset    Offset
 }
 
@@ -147,6 +187,10 @@ func (s *While) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *While) Pos() Offset {
+	return s.Offset
+}
+
 type Break struct {
 	Offset Offset
 }
@@ -159,6 +203,10 @@ func (s *Break) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
 
+func (s *Break) Pos() Offset {
+	return s.Offset
+}
+
 type Continue struct {
 	Offset Offset
 }
@@ -170,3 +218,91 @@ func (s *Continue) Accept(visitor StmtVisitor) any {
 func (s *Continue) AcceptString(visitor StmtVisitor) string {
 	return s.Accept(visitor).(string)
 }
+
+func (s *Continue) Pos() Offset {
+	return s.Offset
+}
+
+type Throw struct {
+	Keyword *scanner.Token
+	Value   Expr
+	Offset  Offset
+}
+
+func (s *Throw) Accept(visitor StmtVisitor) any {
+	return visitor.VisitThrowStmt(s)
+}
+
+func (s *Throw) AcceptString(visitor StmtVisitor) string {
+	return s.Accept(visitor).(string)
+}
+
+func (s *Throw) Pos() Offset {
+	return s.Offset
+}
+
+// Try runs Body. A value thrown in it is bound to CatchName while CatchBody
+// runs, and Finally runs however the statement is left. Either CatchBody or
+// Finally may be nil, but not both.
+type Try struct {
+	Body      *Block
+	CatchName *scanner.Token
+	CatchBody *Block
+	Finally   *Block
+	Offset    Offset
+}
+
+func (s *Try) Accept(visitor StmtVisitor) any {
+	return visitor.VisitTryStmt(s)
+}
+
+func (s *Try) AcceptString(visitor StmtVisitor) string {
+	return s.Accept(visitor).(string)
+}
+
+func (s *Try) Pos() Offset {
+	return s.Offset
+}
+
+// ForIn runs Body once for each value Iterable produces, with Name bound to
+// the value in a new scope each time.
+type ForIn struct {
+	Name     *scanner.Token
+	Iterable Expr
+	Body     Stmt
+	Offset   Offset
+}
+
+func (s *ForIn) Accept(visitor StmtVisitor) any {
+	return visitor.VisitForInStmt(s)
+}
+
+func (s *ForIn) AcceptString(visitor StmtVisitor) string {
+	return s.Accept(visitor).(string)
+}
+
+func (s *ForIn) Pos() Offset {
+	return s.Offset
+}
+
+// Import loads the module at Path. `import "p" as a;` binds the module to
+// Alias; `from "p" import a, b;` binds the listed members, and Alias is nil.
+type Import struct {
+	Keyword *scanner.Token
+	Path    *scanner.Token
+	Alias   *scanner.Token
+	Names   []*scanner.Token
+	Offset  Offset
+}
+
+func (s *Import) Accept(visitor StmtVisitor) any {
+	return visitor.VisitImportStmt(s)
+}
+
+func (s *Import) AcceptString(visitor StmtVisitor) string {
+	return s.Accept(visitor).(string)
+}
+
+func (s *Import) Pos() Offset {
+	return s.Offset
+}
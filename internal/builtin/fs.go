@@ -0,0 +1,286 @@
+package builtin
+
+import (
+	"bufio"
+	"errors"
+	"internal/diagnostic"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The fs module. Scripts may only touch files under the directories allowed
+// on the command line, with --allow-read and --allow-write; by default they
+// can touch none. Relative paths are resolved against the working directory.
+func init() {
+	defineModule("fs", map[string]any{
+		"readFile":   native(1, fsReadFile),
+		"writeFile":  native(2, fsWriteFile),
+		"appendFile": native(2, fsAppendFile),
+		"exists":     native(1, fsExists),
+		"listDir":    native(1, fsListDir),
+		"open":       native(1, fsOpen),
+	})
+}
+
+var readRoots, writeRoots []string
+
+// AllowRead lets scripts read files and list directories under dir.
+func AllowRead(dir string) error {
+	root, err := accessRoot(dir)
+	if err != nil {
+		return err
+	}
+
+	readRoots = append(readRoots, root)
+
+	return nil
+}
+
+// AllowWrite lets scripts create and change files under dir.
+func AllowWrite(dir string) error {
+	root, err := accessRoot(dir)
+	if err != nil {
+		return err
+	}
+
+	writeRoots = append(writeRoots, root)
+
+	return nil
+}
+
+func accessRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
+
+// checkAccess returns path made absolute, with symbolic links resolved so a
+// link cannot lead out of the roots, if it lies under one of them.
+func checkAccess(name string, path any, roots []string, flag string) (string, error) {
+	p, err := toStr(name, path)
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", NewError("%s: %v", name, err)
+	}
+
+	resolved := resolveLinks(abs)
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+
+	return "", NewCodedError(diagnostic.CodePermission, "%s: access to %s denied; run with %s=DIR to allow it", name, p, flag)
+}
+
+// resolveLinks resolves the symbolic links in the longest existing prefix of
+// abs, which may name a file that does not exist yet.
+func resolveLinks(abs string) string {
+	missing := ""
+
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, missing)
+		}
+
+		if filepath.Dir(dir) == dir {
+			return abs
+		}
+
+		missing = filepath.Join(filepath.Base(dir), missing)
+	}
+}
+
+// fsError reports a failed file operation of the function name on path, as
+// the script named it.
+func fsError(name string, path any, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return NewError("%s: %s: %v", name, path, err)
+}
+
+func fsReadFile(host Host, arguments []any) (any, error) {
+	path, err := checkAccess("readFile", arguments[0], readRoots, "--allow-read")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fsError("readFile", arguments[0], err)
+	}
+
+	return string(data), nil
+}
+
+func fsWriteFile(host Host, arguments []any) (any, error) {
+	return nil, writeFile("writeFile", arguments, os.O_TRUNC)
+}
+
+func fsAppendFile(host Host, arguments []any) (any, error) {
+	return nil, writeFile("appendFile", arguments, os.O_APPEND)
+}
+
+// writeFile writes a string to a file, creating it if needed; mode says
+// whether to replace or append to what is there.
+func writeFile(name string, arguments []any, mode int) error {
+	path, err := checkAccess(name, arguments[0], writeRoots, "--allow-write")
+	if err != nil {
+		return err
+	}
+
+	text, err := toStr(name, arguments[1])
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|mode, 0644)
+	if err != nil {
+		return fsError(name, arguments[0], err)
+	}
+
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fsError(name, arguments[0], err)
+	}
+
+	return nil
+}
+
+func fsExists(host Host, arguments []any) (any, error) {
+	path, err := checkAccess("exists", arguments[0], readRoots, "--allow-read")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(path)
+
+	return err == nil, nil
+}
+
+// fsListDir returns the names in a directory, sorted.
+func fsListDir(host Host, arguments []any) (any, error) {
+	path, err := checkAccess("listDir", arguments[0], readRoots, "--allow-read")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fsError("listDir", arguments[0], err)
+	}
+
+	names := make([]any, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+
+	return NewList(names), nil
+}
+
+func fsOpen(host Host, arguments []any) (any, error) {
+	path, err := checkAccess("open", arguments[0], readRoots, "--allow-read")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fsError("open", arguments[0], err)
+	}
+
+	return &File{name: arguments[0].(string), file: file, reader: bufio.NewReader(file)}, nil
+}
+
+// File is a file opened for reading line by line. A for-in loop over it
+// visits its remaining lines and closes it once they run out.
+type File struct {
+	name   string
+	file   *os.File
+	reader *bufio.Reader
+	closed bool
+}
+
+func (f *File) TypeName() string {
+	return "file"
+}
+
+func (f *File) String() string {
+	return "<file " + f.name + ">"
+}
+
+var fileMethods = map[string]method[*File]{
+	"readLine": {0, fileReadLine},
+	"close":    {0, fileClose},
+}
+
+func (f *File) Method(name string) (*Native, bool) {
+	return bindMethod(fileMethods, f, name)
+}
+
+// ReadLine returns the next line without its line ending, or false at the
+// end of the file.
+func (f *File) ReadLine() (string, bool, error) {
+	if f.closed {
+		return "", false, NewError("readLine: %s is closed", f.name)
+	}
+
+	line, err := f.reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, fsError("readLine", f.name, err)
+	}
+
+	if line == "" && err != nil {
+		return "", false, nil
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), true, nil
+}
+
+// fileReadLine returns the next line, or nil at the end of the file.
+func fileReadLine(host Host, f *File, arguments []any) (any, error) {
+	line, ok, err := f.ReadLine()
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func fileClose(host Host, f *File, arguments []any) (any, error) {
+	return nil, f.Close()
+}
+
+// Close closes the file. Closing it again does nothing.
+func (f *File) Close() error {
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+
+	if err := f.file.Close(); err != nil {
+		return fsError("close", f.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// allowDirs makes read and write the only directories scripts may touch
+// until the test ends.
+func allowDirs(t *testing.T, read string, write string) {
+	t.Helper()
+
+	oldRead, oldWrite := readRoots, writeRoots
+	readRoots, writeRoots = nil, nil
+	t.Cleanup(func() { readRoots, writeRoots = oldRead, oldWrite })
+
+	if err := AllowRead(read); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AllowWrite(write); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	allowDirs(t, dir, dir)
+
+	data := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(data, []byte("hp=10\r\natk=3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	save := filepath.Join(dir, "save.txt")
+	missing := filepath.Join(dir, "missing.txt")
+	escaped := filepath.Join(dir, "link", "x.txt")
+	above := filepath.Join(dir, "..", "x.txt")
+
+	runCallTests(t, []callTest{
+		{call: "fs.readFile", args: []any{data}, want: "hp=10\r\natk=3"},
+		{call: "fs.readFile", args: []any{missing}, wantErr: "readFile: " + missing + ": no such file or directory"},
+		{call: "fs.readFile", args: []any{int64(1)}, wantErr: "readFile: argument must be a string, not a number", wantCode: diagnostic.CodeType},
+		{call: "fs.readFile", args: []any{outside}, wantErr: "readFile: access to " + outside + " denied; run with --allow-read=DIR to allow it", wantCode: diagnostic.CodePermission},
+		{call: "fs.readFile", args: []any{above}, wantErr: "readFile: access to " + above + " denied; run with --allow-read=DIR to allow it", wantCode: diagnostic.CodePermission},
+
+		{call: "fs.writeFile", args: []any{save, "a"}, want: nil},
+		{call: "fs.appendFile", args: []any{save, "b"}, want: nil},
+		{call: "fs.readFile", args: []any{save}, want: "ab"},
+		{call: "fs.writeFile", args: []any{save, "c"}, want: nil},
+		{call: "fs.readFile", args: []any{save}, want: "c"},
+		{call: "fs.writeFile", args: []any{save, int64(1)}, wantErr: "writeFile: argument must be a string, not a number", wantCode: diagnostic.CodeType},
+		{call: "fs.writeFile", args: []any{escaped, "x"}, wantErr: "writeFile: access to " + escaped + " denied; run with --allow-write=DIR to allow it", wantCode: diagnostic.CodePermission},
+
+		{call: "fs.exists", args: []any{data}, want: true},
+		{call: "fs.exists", args: []any{missing}, want: false},
+		{call: "fs.listDir", args: []any{dir}, want: list("data.txt", "link", "save.txt")},
+		{call: "fs.listDir", args: []any{data}, wantErr: "listDir: " + data + ": not a directory"},
+	})
+}
+
+func TestFS_ReadOnlyDirectoryIsNotWritable(t *testing.T) {
+	dir := t.TempDir()
+	allowDirs(t, dir, t.TempDir())
+
+	path := filepath.Join(dir, "x.txt")
+
+	runCallTests(t, []callTest{
+		{call: "fs.writeFile", args: []any{path, "x"}, wantErr: "writeFile: access to " + path + " denied; run with --allow-write=DIR to allow it", wantCode: diagnostic.CodePermission},
+	})
+}
+
+func TestFile_ForInReadsLinesAndCloses(t *testing.T) {
+	dir := t.TempDir()
+	allowDirs(t, dir, dir)
+
+	path := filepath.Join(dir, "lines.txt")
+	if err := os.WriteFile(path, []byte("one\r\ntwo\n\nthree"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := callStd(t, "fs.open", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := opened.(*File)
+
+	first, err := fileReadLine(&testHost{}, f, nil)
+	if err != nil || first != "one" {
+		t.Fatalf("readLine: got %v, error %v; want \"one\"", first, err)
+	}
+
+	iterator, err := Iterate(&testHost{}, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []any
+	for {
+		line, ok, err := iterator.Next(&testHost{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			break
+		}
+
+		lines = append(lines, line)
+	}
+
+	if want := list("two", "", "three"); !reflect.DeepEqual(NewList(lines), want) {
+		t.Fatalf("got lines %v, want %v", lines, want.Elements)
+	}
+
+	if !f.closed {
+		t.Fatal("the file is still open after the loop read all of it")
+	}
+
+	if _, err := fileReadLine(&testHost{}, f, nil); err == nil || err.Error() != "readLine: "+path+" is closed" {
+		t.Fatalf("readLine after the loop: got error %v", err)
+	}
+}
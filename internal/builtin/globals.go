@@ -0,0 +1,182 @@
+package builtin
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func init() {
+	define("print", 1, fnPrint)
+	define("input", 1, fnInput)
+	define("clock", 0, fnClock)
+	define("str", 1, fnToString)
+	define("int", 1, fnToInt)
+	define("float", 1, fnToFloat)
+	define("rand", 0, fnRand)
+	define("randInt", 1, fnRandInt)
+	define("sleep", 1, fnSleep)
+	define("clear", 0, fnClear)
+	define("strlen", 1, fnStrLen)
+	define("substring", 3, fnSubstring)
+	define("getch", 0, fnGetch)
+	define("range", 3, fnRange)
+}
+
+func fnPrint(host Host, arguments []any) (any, error) {
+	fmt.Fprintln(host.Stdout(), arguments[0])
+
+	return nil, nil
+}
+
+func fnInput(host Host, arguments []any) (any, error) {
+	fmt.Fprint(host.Stdout(), arguments[0])
+	line, err := host.Stdin().ReadString('\n')
+
+	if err != nil && line == "" {
+		return nil, NewError("failed to read input")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// fnClock returns the wall-clock time in seconds as a float. Timing code
+// should prefer time.millis and time.nanos, which cannot jump backwards.
+func fnClock(host Host, arguments []any) (any, error) {
+	return float64(time.Now().UnixNano()) / 1e9, nil
+}
+
+func fnToString(host Host, arguments []any) (any, error) {
+	return fmt.Sprint(arguments[0]), nil
+}
+
+func fnToInt(host Host, arguments []any) (any, error) {
+	v, err := strconv.ParseInt(fmt.Sprint(arguments[0]), 10, 64)
+	if err != nil {
+		return nil, NewError("int: cannot convert %q", fmt.Sprint(arguments[0]))
+	}
+
+	return v, nil
+}
+
+func fnToFloat(host Host, arguments []any) (any, error) {
+	v, err := strconv.ParseFloat(fmt.Sprint(arguments[0]), 64)
+	if err != nil {
+		return nil, NewError("float: cannot convert %q", fmt.Sprint(arguments[0]))
+	}
+
+	return v, nil
+}
+
+func fnRand(host Host, arguments []any) (any, error) {
+	return rand.Float64(), nil
+}
+
+func fnRandInt(host Host, arguments []any) (any, error) {
+	// Accept int64 or float64; convert via fmt then ParseInt fallback
+	var n int64
+	switch v := arguments[0].(type) {
+	case int64:
+		n = v
+	case float64:
+		n = int64(v)
+	default:
+		// try parsing string rep
+		parsed, err := strconv.ParseInt(fmt.Sprint(arguments[0]), 10, 64)
+		if err != nil {
+			return nil, NewError("randInt argument must be a number")
+		}
+		n = parsed
+	}
+	if n <= 0 {
+		return nil, NewError("randInt argument must be > 0")
+	}
+	return int64(rand.Int63n(n)), nil
+}
+
+func fnSleep(host Host, arguments []any) (any, error) {
+	var ms int64
+	switch v := arguments[0].(type) {
+	case int64:
+		ms = v
+	case float64:
+		ms = int64(v)
+	default:
+		parsed, err := strconv.ParseInt(fmt.Sprint(arguments[0]), 10, 64)
+		if err != nil {
+			return nil, NewError("sleep argument must be a number (milliseconds)")
+		}
+		ms = parsed
+	}
+	if ms < 0 {
+		return nil, NewError("sleep argument must be >= 0")
+	}
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return nil, nil
+}
+
+func fnClear(host Host, arguments []any) (any, error) {
+	// ANSI escape: clear screen & move cursor home
+	fmt.Fprint(host.Stdout(), "\033[2J\033[H")
+	return nil, nil
+}
+
+func fnStrLen(host Host, arguments []any) (any, error) {
+	s := fmt.Sprint(arguments[0])
+	return int64(utf8.RuneCountInString(s)), nil
+}
+
+func fnSubstring(host Host, arguments []any) (any, error) {
+	s := fmt.Sprint(arguments[0])
+	start, ok1 := ToInt(arguments[1])
+	end, ok2 := ToInt(arguments[2])
+	if !ok1 || !ok2 {
+		return nil, NewError("substring indices must be numbers")
+	}
+	runes := []rune(s)
+	if start < 0 || end < 0 || start > end || int(end) > len(runes) {
+		return nil, NewError("substring index out of range")
+	}
+	return string(runes[start:end]), nil
+}
+
+func fnGetch(host Host, arguments []any) (any, error) {
+	reader := host.Stdin()
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return nil, NewError("failed to read char")
+	}
+	// If user pressed Enter first, try next rune
+	if r == '\n' || r == '\r' {
+		r, _, err = reader.ReadRune()
+		if err != nil {
+			return nil, NewError("failed to read char")
+		}
+	}
+	return string(r), nil
+}
+
+// ToInt converts a non-negative whole number argument to an int index.
+func ToInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		if n < 0 || n > int64(int(n)) { // overflow check
+			return 0, false
+		}
+		return int(n), true
+	case float64:
+		if n < 0 || n > float64(int(n)) { // not whole or overflow
+			return 0, false
+		}
+		return int(n), true
+	default:
+		parsed, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		if err != nil || parsed < 0 || parsed > int64(int(parsed)) {
+			return 0, false
+		}
+		return int(parsed), true
+	}
+}
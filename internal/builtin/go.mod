@@ -0,0 +1,3 @@
+module internal/builtin
+
+go 1.24.0
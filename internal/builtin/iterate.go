@@ -0,0 +1,189 @@
+package builtin
+
+import (
+	"fmt"
+	"internal/diagnostic"
+)
+
+// Iterator produces the values a for-in loop visits, one at a time.
+type Iterator struct {
+	source any
+	next   func(host Host) (any, bool, error)
+}
+
+// Source is the value being iterated. The VM's collector traces through it
+// while a loop holds the iterator.
+func (it *Iterator) Source() any {
+	return it.source
+}
+
+// Next returns the next value, or false once there are no more.
+func (it *Iterator) Next(host Host) (any, bool, error) {
+	return it.next(host)
+}
+
+func (it *Iterator) String() string {
+	return "<iterator>"
+}
+
+// Iterate returns an iterator over value: the elements of a list, the keys of
+// a map in insertion order, the characters of a string, the numbers of a
+// range, the lines of a file, or what an object's iter() method returns. The
+// object iter() returns is either one of those values or has a next()
+// method, which is called for each value until it returns nil.
+func Iterate(host Host, value any) (*Iterator, error) {
+	switch v := value.(type) {
+	case *List:
+		i := 0
+
+		// the list is read as the loop goes, so elements pushed meanwhile are visited
+		return &Iterator{source: v, next: func(host Host) (any, bool, error) {
+			if i >= len(v.Elements) {
+				return nil, false, nil
+			}
+
+			i++
+
+			return v.Elements[i-1], true, nil
+		}}, nil
+
+	case *Map:
+		return sliceIterator(v, v.Keys()), nil
+
+	case string:
+		runes := []rune(v)
+		chars := make([]any, len(runes))
+		for i, r := range runes {
+			chars[i] = string(r)
+		}
+
+		return sliceIterator(v, chars), nil
+
+	case *Range:
+		current := v.Start
+
+		return &Iterator{source: v, next: func(host Host) (any, bool, error) {
+			if (v.Step > 0 && current >= v.Stop) || (v.Step < 0 && current <= v.Stop) {
+				return nil, false, nil
+			}
+
+			current += v.Step
+
+			return current - v.Step, true, nil
+		}}, nil
+
+	case *Iterator:
+		return v, nil
+
+	case *File:
+		return &Iterator{source: v, next: func(host Host) (any, bool, error) {
+			line, ok, err := v.ReadLine()
+			if !ok {
+				// the loop is over, so nothing else will read the file
+				if closeErr := v.Close(); err == nil {
+					err = closeErr
+				}
+			}
+
+			return line, ok, err
+		}}, nil
+	}
+
+	iter, ok := host.Method(value, "iter")
+	if !ok {
+		return nil, NewCodedError(diagnostic.CodeType, "%s is not iterable", describe(value))
+	}
+
+	iterator, err := host.Call(iter)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := host.Method(iterator, "next"); ok {
+		return objectIterator(iterator), nil
+	}
+
+	switch iterator.(type) {
+	case *List, *Map, string, *Range, *Iterator, *File:
+		return Iterate(host, iterator)
+	}
+
+	return nil, NewCodedError(diagnostic.CodeType, "iter() returned %s, which has no next() method", describe(iterator))
+}
+
+func sliceIterator(source any, values []any) *Iterator {
+	i := 0
+
+	return &Iterator{source: source, next: func(host Host) (any, bool, error) {
+		if i >= len(values) {
+			return nil, false, nil
+		}
+
+		i++
+
+		return values[i-1], true, nil
+	}}
+}
+
+// objectIterator calls the next() method of object until it returns nil.
+func objectIterator(object any) *Iterator {
+	return &Iterator{source: object, next: func(host Host) (any, bool, error) {
+		next, ok := host.Method(object, "next")
+		if !ok {
+			return nil, false, NewCodedError(diagnostic.CodeType, "%s has no next() method", describe(object))
+		}
+
+		value, err := host.Call(next)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return value, value != nil, nil
+	}}
+}
+
+// describe names a value in an error message.
+func describe(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "a string"
+	case int64, float64:
+		return "a number"
+	case bool:
+		return "a bool"
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Range is the sequence of ints from Start up to, but not including, Stop,
+// counting by Step.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+func fnRange(host Host, arguments []any) (any, error) {
+	var bounds [3]int64
+	for i, argument := range arguments {
+		n, ok := argument.(int64)
+		if !ok {
+			return nil, NewCodedError(diagnostic.CodeType, "range arguments must be ints")
+		}
+
+		bounds[i] = n
+	}
+
+	if bounds[2] == 0 {
+		return nil, NewError("range step must not be 0")
+	}
+
+	return &Range{Start: bounds[0], Stop: bounds[1], Step: bounds[2]}, nil
+}
@@ -0,0 +1,280 @@
+package builtin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internal/diagnostic"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The json module. Numbers written without a fraction or exponent parse as
+// ints and the rest as floats; stringify writes whole floats with ".0" so
+// they parse back as floats. Objects become maps that keep their key order.
+func init() {
+	defineModule("json", map[string]any{
+		"parse":     native(1, jsonParse),
+		"stringify": native(2, jsonStringify),
+	})
+}
+
+func jsonParse(host Host, arguments []any) (any, error) {
+	text, err := toStr("parse", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	value, err := decodeJSON(dec)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = errors.New("unexpected data after the value")
+		}
+	}
+
+	if err != nil {
+		return nil, NewError("parse: invalid JSON at offset %d: %v", dec.InputOffset(), jsonErrorText(err))
+	}
+
+	return value, nil
+}
+
+// jsonErrorText drops the "json: " prefix of encoding/json's errors.
+func jsonErrorText(err error) string {
+	// the decoder reports an early end in any of these ways, depending on
+	// where the input stops
+	var syntaxErr *json.SyntaxError
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &syntaxErr) && syntaxErr.Error() == "unexpected end of JSON input" {
+		return "unexpected end of input"
+	}
+
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+func decodeJSON(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '[' {
+			elements := make([]any, 0)
+
+			for dec.More() {
+				element, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+
+				elements = append(elements, element)
+			}
+
+			_, err := dec.Token()
+
+			return NewList(elements), err
+		}
+
+		object := NewMap()
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			object.Set(key, value)
+		}
+
+		_, err := dec.Token()
+
+		return object, err
+
+	case json.Number:
+		if n, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			return n, nil
+		}
+
+		f, err := t.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s is out of range", t)
+		}
+
+		return f, nil
+	}
+
+	// strings, bools and nil
+	return token, nil
+}
+
+// jsonStringify writes value as JSON. indent is nil or 0 for compact output,
+// a number of spaces, or the string to indent each level with.
+func jsonStringify(host Host, arguments []any) (any, error) {
+	var indent string
+
+	switch v := arguments[1].(type) {
+	case nil:
+	case int64:
+		indent = strings.Repeat(" ", int(max(v, 0)))
+	case string:
+		indent = v
+	default:
+		return nil, NewCodedError(diagnostic.CodeType, "stringify: indent must be nil, an int or a string, not %s", describe(v))
+	}
+
+	e := &jsonEncoder{host: host, indent: indent, visiting: make(map[any]bool)}
+	if err := e.encode(arguments[0], 0); err != nil {
+		return nil, err
+	}
+
+	return e.buf.String(), nil
+}
+
+type jsonEncoder struct {
+	host     Host
+	indent   string
+	buf      bytes.Buffer
+	visiting map[any]bool // containers being written, to catch cycles
+}
+
+func (e *jsonEncoder) encode(value any, depth int) error {
+	switch v := value.(type) {
+	case nil:
+		e.buf.WriteString("null")
+
+	case bool:
+		e.buf.WriteString(strconv.FormatBool(v))
+
+	case int64:
+		e.buf.WriteString(strconv.FormatInt(v, 10))
+
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return NewError("stringify: %v has no JSON form", v)
+		}
+
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eE") {
+			text += ".0"
+		}
+
+		e.buf.WriteString(text)
+
+	case string:
+		e.writeString(v)
+
+	case *List:
+		return e.container(v, "[", "]", len(v.Elements), depth, func(i int) error {
+			return e.encode(v.Elements[i], depth+1)
+		})
+
+	case *Map:
+		keys, values := v.Keys(), v.Values()
+
+		return e.container(v, "{", "}", len(keys), depth, func(i int) error {
+			return e.member(jsonKey(keys[i]), values[i], depth)
+		})
+
+	default:
+		fields, ok := e.host.Fields(value)
+		if !ok {
+			return NewCodedError(diagnostic.CodeType, "stringify: %s cannot be written as JSON", describe(value))
+		}
+
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+
+		// fields have no order of their own
+		slices.Sort(names)
+
+		return e.container(value, "{", "}", len(names), depth, func(i int) error {
+			return e.member(names[i], fields[names[i]], depth)
+		})
+	}
+
+	return nil
+}
+
+// container writes n elements between open and close, one per line when
+// indenting.
+func (e *jsonEncoder) container(value any, open, close string, n int, depth int, element func(i int) error) error {
+	if e.visiting[value] {
+		return NewError("stringify: cannot write a structure that contains itself")
+	}
+
+	e.visiting[value] = true
+	defer delete(e.visiting, value)
+
+	e.buf.WriteString(open)
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			e.buf.WriteString(",")
+		}
+
+		e.newline(depth + 1)
+
+		if err := element(i); err != nil {
+			return err
+		}
+	}
+
+	if n > 0 {
+		e.newline(depth)
+	}
+
+	e.buf.WriteString(close)
+
+	return nil
+}
+
+func (e *jsonEncoder) member(key string, value any, depth int) error {
+	e.writeString(key)
+	e.buf.WriteString(":")
+
+	if e.indent != "" {
+		e.buf.WriteString(" ")
+	}
+
+	return e.encode(value, depth+1)
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent != "" {
+		e.buf.WriteString("\n" + strings.Repeat(e.indent, depth))
+	}
+}
+
+func (e *jsonEncoder) writeString(s string) {
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// Encode ends the value with a newline
+	e.buf.Truncate(e.buf.Len() - 1)
+}
+
+// jsonKey turns a map key into an object key; JSON keys are always strings.
+func jsonKey(key any) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+
+	return formatElement(key)
+}
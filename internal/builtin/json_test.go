@@ -0,0 +1,55 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"math"
+	"testing"
+)
+
+// mapOf makes a map from keys and values in turn, in that order.
+func mapOf(pairs ...any) *Map {
+	m := NewMap()
+	for i := 0; i < len(pairs); i += 2 {
+		m.Set(pairs[i], pairs[i+1])
+	}
+
+	return m
+}
+
+func TestJSON(t *testing.T) {
+	self := list(int64(1))
+	self.Elements = append(self.Elements, self)
+
+	shared := list(int64(1))
+
+	runCallTests(t, []callTest{
+		{call: "json.parse", args: []any{`{"b": [1, 2.5, 1e2, -0], "a": null, "c": true, "d": "é\n"}`},
+			want: mapOf("b", list(int64(1), 2.5, 100.0, int64(0)), "a", nil, "c", true, "d", "é\n")},
+		{call: "json.parse", args: []any{` [] `}, want: NewList([]any{})},
+		{call: "json.parse", args: []any{`{}`}, want: mapOf()},
+		{call: "json.parse", args: []any{`9223372036854775808`}, want: 9223372036854775808.0},
+		{call: "json.parse", args: []any{`{"a": 1, "a": 2}`}, want: mapOf("a", int64(2))},
+
+		{call: "json.parse", args: []any{``}, wantErr: "parse: invalid JSON at offset 0: unexpected end of input"},
+		{call: "json.parse", args: []any{`[1, 2`}, wantErr: "parse: invalid JSON at offset 5: unexpected end of input"},
+		{call: "json.parse", args: []any{`"abc`}, wantErr: "parse: invalid JSON at offset 0: unexpected end of input"},
+		{call: "json.parse", args: []any{`[1] 2`}, wantErr: "parse: invalid JSON at offset 5: unexpected data after the value"},
+		{call: "json.parse", args: []any{`{"a" 1}`}, wantErr: "parse: invalid JSON at offset 4: invalid character '1' after object key"},
+		{call: "json.parse", args: []any{`{1: 2}`}, wantErr: "parse: invalid JSON at offset 1: object member name must be a string"},
+		{call: "json.parse", args: []any{`[1e400]`}, wantErr: "parse: invalid JSON at offset 6: number 1e400 is out of range"},
+		{call: "json.parse", args: []any{nil}, wantErr: "parse: argument must be a string, not nil", wantCode: diagnostic.CodeType},
+
+		{call: "json.stringify", args: []any{mapOf("b", list(int64(1), 2.0, 0.5, nil), "a", "<\"é\">"), nil},
+			want: `{"b":[1,2.0,0.5,null],"a":"<\"é\">"}`},
+		{call: "json.stringify", args: []any{mapOf(int64(1), true, 2.5, false), nil}, want: `{"1":true,"2.5":false}`},
+		{call: "json.stringify", args: []any{mapOf("a", list(int64(1), NewList(nil)), "b", mapOf()), int64(2)},
+			want: "{\n  \"a\": [\n    1,\n    []\n  ],\n  \"b\": {}\n}"},
+		{call: "json.stringify", args: []any{list(int64(1)), "\t"}, want: "[\n\t1\n]"},
+		{call: "json.stringify", args: []any{list(int64(1)), int64(0)}, want: "[1]"},
+		{call: "json.stringify", args: []any{list(shared, shared), nil}, want: "[[1],[1]]"},
+		{call: "json.stringify", args: []any{self, nil}, wantErr: "stringify: cannot write a structure that contains itself"},
+		{call: "json.stringify", args: []any{math.Inf(1), nil}, wantErr: "stringify: +Inf has no JSON form"},
+		{call: "json.stringify", args: []any{&Range{Start: 0, Stop: 3, Step: 1}, nil}, wantErr: "stringify: range(0, 3, 1) cannot be written as JSON", wantCode: diagnostic.CodeType},
+		{call: "json.stringify", args: []any{nil, true}, wantErr: "stringify: indent must be nil, an int or a string, not a bool", wantCode: diagnostic.CodeType},
+	})
+}
@@ -0,0 +1,309 @@
+package builtin
+
+import (
+	"cmp"
+	"fmt"
+	"internal/diagnostic"
+	"internal/util"
+	"slices"
+	"strings"
+)
+
+// List is a HOLang list. Both engines use it as is, so natives can take and
+// return lists without converting them.
+type List struct {
+	Elements []any
+}
+
+func NewList(elements []any) *List {
+	return &List{
+		Elements: elements,
+	}
+}
+
+func (l *List) TypeName() string {
+	return "list"
+}
+
+func (l *List) String() string {
+	var builder strings.Builder
+	writeElement(&builder, l, make(map[any]bool))
+
+	return builder.String()
+}
+
+// GetIndex evaluates object[index]. Lists and strings take an int index, and
+// a negative index counts back from the end; maps take a key.
+func GetIndex(object any, index any) (any, error) {
+	switch o := object.(type) {
+	case *List:
+		i, err := elementIndex("list", index, len(o.Elements))
+		if err != nil {
+			return nil, err
+		}
+
+		return o.Elements[i], nil
+
+	case *Map:
+		value, ok, err := o.Get(index)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, NewCodedError(diagnostic.CodeIndex, "map has no key %s", formatElement(index))
+		}
+
+		return value, nil
+
+	case string:
+		runes := []rune(o)
+
+		i, err := elementIndex("string", index, len(runes))
+		if err != nil {
+			return nil, err
+		}
+
+		return string(runes[i]), nil
+	}
+
+	return nil, NewCodedError(diagnostic.CodeType, "only lists, maps and strings can be indexed")
+}
+
+// SetIndex evaluates object[index] = value.
+func SetIndex(object any, index any, value any) error {
+	if m, ok := object.(*Map); ok {
+		return m.Set(index, value)
+	}
+
+	list, ok := object.(*List)
+	if !ok {
+		return NewCodedError(diagnostic.CodeType, "only list elements and map entries can be assigned")
+	}
+
+	i, err := elementIndex("list", index, len(list.Elements))
+	if err != nil {
+		return err
+	}
+
+	list.Elements[i] = value
+
+	return nil
+}
+
+// elementIndex checks index against a sequence of length elements and turns
+// a negative index into one counted from the start.
+func elementIndex(what string, index any, length int) (int, error) {
+	i, ok := index.(int64)
+	if !ok {
+		return 0, NewCodedError(diagnostic.CodeType, "%s index must be an int", what)
+	}
+
+	resolved := i
+	if resolved < 0 {
+		resolved += int64(length)
+	}
+
+	if resolved < 0 || resolved >= int64(length) {
+		return 0, NewCodedError(diagnostic.CodeIndex, "%s index out of range: %d", what, i)
+	}
+
+	return int(resolved), nil
+}
+
+var listMethods = map[string]method[*List]{
+	"len":    {0, listLen},
+	"push":   {1, listPush},
+	"pop":    {0, listPop},
+	"insert": {2, listInsert},
+	"remove": {1, listRemove},
+	"slice":  {2, listSlice},
+	"sort":   {0, listSort},
+	"map":    {1, listMap},
+	"filter": {1, listFilter},
+	"join":   {1, listJoin},
+}
+
+func (l *List) Method(name string) (*Native, bool) {
+	return bindMethod(listMethods, l, name)
+}
+
+func listLen(host Host, list *List, arguments []any) (any, error) {
+	return int64(len(list.Elements)), nil
+}
+
+func listPush(host Host, list *List, arguments []any) (any, error) {
+	list.Elements = append(list.Elements, arguments[0])
+
+	return nil, nil
+}
+
+func listPop(host Host, list *List, arguments []any) (any, error) {
+	if len(list.Elements) == 0 {
+		return nil, NewCodedError(diagnostic.CodeIndex, "pop from empty list")
+	}
+
+	last := list.Elements[len(list.Elements)-1]
+	list.Elements = list.Elements[:len(list.Elements)-1]
+
+	return last, nil
+}
+
+// listInsert puts a value before index; an index equal to the length appends.
+func listInsert(host Host, list *List, arguments []any) (any, error) {
+	i, err := elementIndex("insert", arguments[0], len(list.Elements)+1)
+	if err != nil {
+		return nil, err
+	}
+
+	list.Elements = slices.Insert(list.Elements, i, arguments[1])
+
+	return nil, nil
+}
+
+// listRemove deletes the element at index and returns it.
+func listRemove(host Host, list *List, arguments []any) (any, error) {
+	i, err := elementIndex("remove", arguments[0], len(list.Elements))
+	if err != nil {
+		return nil, err
+	}
+
+	removed := list.Elements[i]
+	list.Elements = slices.Delete(list.Elements, i, i+1)
+
+	return removed, nil
+}
+
+// listSlice copies the elements from start up to end. Bounds past either end
+// are clamped, and a nil end means the end of the list.
+func listSlice(host Host, list *List, arguments []any) (any, error) {
+	length := len(list.Elements)
+
+	start, err := sliceBound(arguments[0], length, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := sliceBound(arguments[1], length, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if end < start {
+		end = start
+	}
+
+	return NewList(slices.Clone(list.Elements[start:end])), nil
+}
+
+func sliceBound(bound any, length int, missing int) (int, error) {
+	if bound == nil {
+		return missing, nil
+	}
+
+	i, ok := bound.(int64)
+	if !ok {
+		return 0, NewCodedError(diagnostic.CodeType, "slice bounds must be ints")
+	}
+
+	if i < 0 {
+		i += int64(length)
+	}
+
+	return int(min(max(i, 0), int64(length))), nil
+}
+
+// listSort sorts numbers or strings in place, keeping equal elements in order.
+func listSort(host Host, list *List, arguments []any) (any, error) {
+	sorted := slices.Clone(list.Elements)
+
+	var err error
+	slices.SortStableFunc(sorted, func(a, b any) int {
+		c, cmpErr := compareValues(a, b)
+		if cmpErr != nil && err == nil {
+			err = cmpErr
+		}
+
+		return c
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	copy(list.Elements, sorted)
+
+	return nil, nil
+}
+
+func compareValues(a any, b any) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, y), nil
+		case float64:
+			return cmp.Compare(float64(x), y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, float64(y)), nil
+		case float64:
+			return cmp.Compare(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+
+	return 0, NewCodedError(diagnostic.CodeType, "sort: cannot compare %v and %v", a, b)
+}
+
+func listMap(host Host, list *List, arguments []any) (any, error) {
+	result := make([]any, 0, len(list.Elements))
+
+	for _, element := range list.Elements {
+		value, err := host.Call(arguments[0], element)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, value)
+	}
+
+	return NewList(result), nil
+}
+
+func listFilter(host Host, list *List, arguments []any) (any, error) {
+	result := make([]any, 0)
+
+	for _, element := range list.Elements {
+		keep, err := host.Call(arguments[0], element)
+		if err != nil {
+			return nil, err
+		}
+
+		if util.IsTruthy(keep) {
+			result = append(result, element)
+		}
+	}
+
+	return NewList(result), nil
+}
+
+// listJoin concatenates the elements, converted as str does, with sep between them.
+func listJoin(host Host, list *List, arguments []any) (any, error) {
+	sep, ok := arguments[0].(string)
+	if !ok {
+		return nil, NewCodedError(diagnostic.CodeType, "join separator must be a string")
+	}
+
+	parts := make([]string, len(list.Elements))
+	for i, element := range list.Elements {
+		parts[i] = fmt.Sprint(element)
+	}
+
+	return strings.Join(parts, sep), nil
+}
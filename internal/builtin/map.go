@@ -0,0 +1,164 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Map is a HOLang map. Keys are strings, numbers or bools, and entries are
+// kept in the order their keys were first added.
+type Map struct {
+	keys   []any
+	values map[any]any
+}
+
+func NewMap() *Map {
+	return &Map{
+		values: make(map[any]any),
+	}
+}
+
+func (m *Map) TypeName() string {
+	return "map"
+}
+
+func (m *Map) String() string {
+	var builder strings.Builder
+	writeElement(&builder, m, make(map[any]bool))
+
+	return builder.String()
+}
+
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys in insertion order.
+func (m *Map) Keys() []any {
+	return slices.Clone(m.keys)
+}
+
+// Values returns the values in the order of their keys.
+func (m *Map) Values() []any {
+	values := make([]any, len(m.keys))
+	for i, key := range m.keys {
+		values[i] = m.values[key]
+	}
+
+	return values
+}
+
+func (m *Map) Get(key any) (any, bool, error) {
+	k, err := mapKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := m.values[k]
+
+	return value, ok, nil
+}
+
+// Set adds or replaces an entry. A replaced entry keeps its position.
+func (m *Map) Set(key any, value any) error {
+	k, err := mapKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m.values[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+
+	m.values[k] = value
+
+	return nil
+}
+
+// Delete removes an entry and reports whether there was one.
+func (m *Map) Delete(key any) (bool, error) {
+	k, err := mapKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := m.values[k]; !ok {
+		return false, nil
+	}
+
+	delete(m.values, k)
+	m.keys = slices.DeleteFunc(m.keys, func(existing any) bool {
+		return existing == k
+	})
+
+	return true, nil
+}
+
+// mapKey checks that key can be a map key. A whole float is stored as an int,
+// so m[1] and m[1.0] are the same entry, as 1 == 1.0 is true.
+func mapKey(key any) (any, error) {
+	switch k := key.(type) {
+	case string, bool, int64:
+		return k, nil
+	case float64:
+		if k == math.Trunc(k) && k >= math.MinInt64 && k < math.MaxInt64 {
+			return int64(k), nil
+		}
+
+		return k, nil
+	}
+
+	return nil, NewCodedError(diagnostic.CodeType, "map keys must be strings, numbers or bools")
+}
+
+var mapMethods = map[string]method[*Map]{
+	"len":    {0, mapLen},
+	"keys":   {0, mapKeys},
+	"values": {0, mapValues},
+	"has":    {1, mapHas},
+	"get":    {2, mapGet},
+	"delete": {1, mapDelete},
+}
+
+func (m *Map) Method(name string) (*Native, bool) {
+	return bindMethod(mapMethods, m, name)
+}
+
+func mapLen(host Host, m *Map, arguments []any) (any, error) {
+	return int64(m.Len()), nil
+}
+
+func mapKeys(host Host, m *Map, arguments []any) (any, error) {
+	return NewList(m.Keys()), nil
+}
+
+func mapValues(host Host, m *Map, arguments []any) (any, error) {
+	return NewList(m.Values()), nil
+}
+
+func mapHas(host Host, m *Map, arguments []any) (any, error) {
+	_, ok, err := m.Get(arguments[0])
+
+	return ok, err
+}
+
+// mapGet returns the value for a key, or the second argument if there is none.
+func mapGet(host Host, m *Map, arguments []any) (any, error) {
+	value, ok, err := m.Get(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return arguments[1], nil
+	}
+
+	return value, nil
+}
+
+// mapDelete removes a key and reports whether it was there.
+func mapDelete(host Host, m *Map, arguments []any) (any, error) {
+	return m.Delete(arguments[0])
+}
@@ -0,0 +1,299 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"math"
+)
+
+// The math module. Functions that only move a number around, like abs, min
+// and clamp, keep ints as ints and otherwise follow arithmetic: a float
+// operand makes the result a float. floor, ceil and round return ints; the
+// rest return floats. Arguments outside a function's domain are errors
+// rather than NaN.
+func init() {
+	defineModule("math", map[string]any{
+		"pi": math.Pi,
+		"e":  math.E,
+
+		"abs":   native(1, mathAbs),
+		"min":   native(2, mathMin),
+		"max":   native(2, mathMax),
+		"clamp": native(3, mathClamp),
+		"floor": native(1, roundingFn("floor", math.Floor)),
+		"ceil":  native(1, roundingFn("ceil", math.Ceil)),
+		"round": native(1, roundingFn("round", math.Round)),
+		"pow":   native(2, mathPow),
+
+		"sqrt":  native(1, floatFn("sqrt", math.Sqrt, func(x float64) bool { return x >= 0 })),
+		"exp":   native(1, floatFn("exp", math.Exp, nil)),
+		"log":   native(1, floatFn("log", math.Log, positive)),
+		"log2":  native(1, floatFn("log2", math.Log2, positive)),
+		"log10": native(1, floatFn("log10", math.Log10, positive)),
+		"sin":   native(1, floatFn("sin", math.Sin, nil)),
+		"cos":   native(1, floatFn("cos", math.Cos, nil)),
+		"tan":   native(1, floatFn("tan", math.Tan, nil)),
+		"asin":  native(1, floatFn("asin", math.Asin, unit)),
+		"acos":  native(1, floatFn("acos", math.Acos, unit)),
+		"atan":  native(1, floatFn("atan", math.Atan, nil)),
+		"atan2": native(2, mathAtan2),
+	})
+}
+
+func positive(x float64) bool {
+	return x > 0
+}
+
+func unit(x float64) bool {
+	return x >= -1 && x <= 1
+}
+
+// toFloat reads a numeric argument of the function name.
+func toFloat(name string, value any) (float64, error) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+
+	return 0, NewCodedError(diagnostic.CodeType, "%s: argument must be a number, not %s", name, describe(value))
+}
+
+// floatFn makes a function of one float. inDomain, if set, rejects arguments
+// the function is undefined for.
+func floatFn(name string, fn func(float64) float64, inDomain func(float64) bool) Fn {
+	return func(host Host, arguments []any) (any, error) {
+		x, err := toFloat(name, arguments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if inDomain != nil && !inDomain(x) {
+			return nil, NewError("%s: argument out of domain: %v", name, arguments[0])
+		}
+
+		return fn(x), nil
+	}
+}
+
+// roundingFn makes floor, ceil or round, which turn a float into the int it
+// rounds to. round rounds halves away from zero.
+func roundingFn(name string, fn func(float64) float64) Fn {
+	return func(host Host, arguments []any) (any, error) {
+		if n, ok := arguments[0].(int64); ok {
+			return n, nil
+		}
+
+		x, err := toFloat(name, arguments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		rounded := fn(x)
+		if math.IsNaN(rounded) || rounded < math.MinInt64 || rounded >= math.MaxInt64 {
+			return nil, NewError("%s: %v does not fit in an int", name, x)
+		}
+
+		return int64(rounded), nil
+	}
+}
+
+// mathAbs fails for the smallest int, whose absolute value is one more than
+// the largest int.
+func mathAbs(host Host, arguments []any) (any, error) {
+	if n, ok := arguments[0].(int64); ok {
+		if n == math.MinInt64 {
+			return nil, NewError("abs: %d does not fit in an int", n)
+		}
+
+		if n < 0 {
+			return -n, nil
+		}
+
+		return n, nil
+	}
+
+	x, err := toFloat("abs", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return math.Abs(x), nil
+}
+
+// compareNumbers orders a and b, reporting whether both are ints.
+func compareNumbers(name string, a, b any) (int, bool, error) {
+	x, err := toFloat(name, a)
+	if err != nil {
+		return 0, false, err
+	}
+
+	y, err := toFloat(name, b)
+	if err != nil {
+		return 0, false, err
+	}
+
+	_, aInt := a.(int64)
+	_, bInt := b.(int64)
+
+	if aInt && bInt {
+		c, _ := compareValues(a, b)
+
+		return c, true, nil
+	}
+
+	switch {
+	case x < y:
+		return -1, false, nil
+	case x > y:
+		return 1, false, nil
+	}
+
+	return 0, false, nil
+}
+
+// pick returns value as it is if the operands were all ints, otherwise as a
+// float.
+func pick(value any, ints bool) any {
+	if ints {
+		return value
+	}
+
+	if n, ok := value.(int64); ok {
+		return float64(n)
+	}
+
+	return value
+}
+
+func mathMin(host Host, arguments []any) (any, error) {
+	c, ints, err := compareNumbers("min", arguments[0], arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if c <= 0 {
+		return pick(arguments[0], ints), nil
+	}
+
+	return pick(arguments[1], ints), nil
+}
+
+func mathMax(host Host, arguments []any) (any, error) {
+	c, ints, err := compareNumbers("max", arguments[0], arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if c >= 0 {
+		return pick(arguments[0], ints), nil
+	}
+
+	return pick(arguments[1], ints), nil
+}
+
+// mathClamp limits x to the range lo to hi.
+func mathClamp(host Host, arguments []any) (any, error) {
+	x, lo, hi := arguments[0], arguments[1], arguments[2]
+
+	order, _, err := compareNumbers("clamp", lo, hi)
+	if err != nil {
+		return nil, err
+	}
+
+	if order > 0 {
+		return nil, NewError("clamp: lower bound %v is above upper bound %v", lo, hi)
+	}
+
+	below, ints, err := compareNumbers("clamp", x, lo)
+	if err != nil {
+		return nil, err
+	}
+
+	above, _, _ := compareNumbers("clamp", x, hi)
+
+	_, hiInt := hi.(int64)
+	ints = ints && hiInt
+
+	switch {
+	case below < 0:
+		return pick(lo, ints), nil
+	case above > 0:
+		return pick(hi, ints), nil
+	}
+
+	return pick(x, ints), nil
+}
+
+// mathPow raises an int to a non-negative int power, failing if the result
+// does not fit in an int as abs does; any other operands give a float.
+func mathPow(host Host, arguments []any) (any, error) {
+	base, baseInt := arguments[0].(int64)
+	exponent, exponentInt := arguments[1].(int64)
+
+	if baseInt && exponentInt && exponent >= 0 {
+		result, ok := int64(1), true
+
+		for exponent > 0 && ok {
+			if exponent&1 == 1 {
+				result, ok = mulInt(result, base)
+			}
+
+			exponent >>= 1
+			if exponent > 0 && ok {
+				base, ok = mulInt(base, base)
+			}
+		}
+
+		if !ok {
+			return nil, NewError("pow: %d to the power %d does not fit in an int", arguments[0], arguments[1])
+		}
+
+		return result, nil
+	}
+
+	x, err := toFloat("pow", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := toFloat("pow", arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	result := math.Pow(x, y)
+	if math.IsNaN(result) {
+		return nil, NewError("pow: %v to the power %v is not a real number", arguments[0], arguments[1])
+	}
+
+	return result, nil
+}
+
+// mulInt multiplies two ints, reporting false if the product overflows.
+func mulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+
+	return product, true
+}
+
+func mathAtan2(host Host, arguments []any) (any, error) {
+	y, err := toFloat("atan2", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := toFloat("atan2", arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return math.Atan2(y, x), nil
+}
@@ -0,0 +1,74 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"math"
+	"testing"
+)
+
+func TestMath(t *testing.T) {
+	runCallTests(t, []callTest{
+		{call: "math.abs", args: []any{int64(-3)}, want: int64(3)},
+		{call: "math.abs", args: []any{-2.5}, want: 2.5},
+		{call: "math.abs", args: []any{int64(math.MinInt64)}, wantErr: "abs: -9223372036854775808 does not fit in an int"},
+		{call: "math.abs", args: []any{"x"}, wantErr: "abs: argument must be a number, not a string", wantCode: diagnostic.CodeType},
+
+		{call: "math.min", args: []any{int64(2), int64(7)}, want: int64(2)},
+		{call: "math.min", args: []any{int64(1), 2.5}, want: 1.0},
+		{call: "math.max", args: []any{int64(2), int64(7)}, want: int64(7)},
+		{call: "math.clamp", args: []any{int64(15), int64(0), int64(10)}, want: int64(10)},
+		{call: "math.clamp", args: []any{-1.5, int64(0), int64(10)}, want: 0.0},
+		{call: "math.clamp", args: []any{int64(5), int64(10), int64(0)}, wantErr: "clamp: lower bound 10 is above upper bound 0"},
+
+		{call: "math.floor", args: []any{2.7}, want: int64(2)},
+		{call: "math.floor", args: []any{-2.5}, want: int64(-3)},
+		{call: "math.ceil", args: []any{2.1}, want: int64(3)},
+		{call: "math.round", args: []any{2.5}, want: int64(3)},
+		{call: "math.round", args: []any{-2.5}, want: int64(-3)},
+		{call: "math.round", args: []any{2.49}, want: int64(2)},
+		{call: "math.round", args: []any{int64(7)}, want: int64(7)},
+		{call: "math.floor", args: []any{1e300}, wantErr: "floor: 1e+300 does not fit in an int"},
+		{call: "math.round", args: []any{math.NaN()}, wantErr: "round: NaN does not fit in an int"},
+
+		{call: "math.pow", args: []any{int64(2), int64(10)}, want: int64(1024)},
+		{call: "math.pow", args: []any{int64(2), int64(62)}, want: int64(1) << 62},
+		{call: "math.pow", args: []any{int64(-2), int64(63)}, want: int64(math.MinInt64)},
+		{call: "math.pow", args: []any{int64(-1), int64(math.MaxInt64)}, want: int64(-1)},
+		{call: "math.pow", args: []any{int64(0), int64(0)}, want: int64(1)},
+		{call: "math.pow", args: []any{int64(2), int64(63)}, wantErr: "pow: 2 to the power 63 does not fit in an int"},
+		{call: "math.pow", args: []any{int64(2), int64(64)}, wantErr: "pow: 2 to the power 64 does not fit in an int"},
+		{call: "math.pow", args: []any{int64(3), int64(41)}, wantErr: "pow: 3 to the power 41 does not fit in an int"},
+		{call: "math.pow", args: []any{int64(-3), int64(39)}, want: int64(-4052555153018976267)},
+		{call: "math.pow", args: []any{int64(2), int64(-1)}, want: 0.5},
+		{call: "math.pow", args: []any{int64(-8), 0.5}, wantErr: "pow: -8 to the power 0.5 is not a real number"},
+
+		{call: "math.sqrt", args: []any{int64(16)}, want: 4.0},
+		{call: "math.sqrt", args: []any{int64(-1)}, wantErr: "sqrt: argument out of domain: -1"},
+		{call: "math.exp", args: []any{int64(0)}, want: 1.0},
+		{call: "math.log", args: []any{math.E}, want: 1.0},
+		{call: "math.log", args: []any{int64(0)}, wantErr: "log: argument out of domain: 0"},
+		{call: "math.log2", args: []any{int64(8)}, want: 3.0},
+		{call: "math.log10", args: []any{int64(100)}, want: 2.0},
+		{call: "math.log10", args: []any{-1.0}, wantErr: "log10: argument out of domain: -1"},
+		{call: "math.sin", args: []any{int64(0)}, want: 0.0},
+		{call: "math.cos", args: []any{int64(0)}, want: 1.0},
+		{call: "math.tan", args: []any{int64(0)}, want: 0.0},
+		{call: "math.asin", args: []any{int64(1)}, want: math.Pi / 2},
+		{call: "math.asin", args: []any{int64(2)}, wantErr: "asin: argument out of domain: 2"},
+		{call: "math.acos", args: []any{int64(1)}, want: 0.0},
+		{call: "math.acos", args: []any{-1.5}, wantErr: "acos: argument out of domain: -1.5"},
+		{call: "math.atan", args: []any{int64(0)}, want: 0.0},
+		{call: "math.atan2", args: []any{int64(1), int64(1)}, want: math.Pi / 4},
+		{call: "math.atan2", args: []any{"y", int64(1)}, wantErr: "atan2: argument must be a number, not a string", wantCode: diagnostic.CodeType},
+	})
+}
+
+func TestMathConstants(t *testing.T) {
+	module, _ := StdModule("math")
+
+	for name, want := range map[string]float64{"pi": math.Pi, "e": math.E} {
+		if got, ok := module.Member(name); !ok || got != want {
+			t.Errorf("math.%s = %v, want %v", name, got, want)
+		}
+	}
+}
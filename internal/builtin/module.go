@@ -0,0 +1,120 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"strings"
+)
+
+// Module is what an import binds: a standard library module, or the globals
+// of another script. Members of a script module are read as they are at the
+// time, so a module sees the updates its own functions make.
+type Module struct {
+	Name   string
+	member func(name string) (any, bool)
+}
+
+func NewModule(name string, member func(name string) (any, bool)) *Module {
+	return &Module{
+		Name:   name,
+		member: member,
+	}
+}
+
+// ModuleName is the name of the module made from file: its base name
+// without the .holang extension.
+func ModuleName(file string) string {
+	name := file[strings.LastIndexAny(file, "/\\")+1:]
+
+	return strings.TrimSuffix(name, ".holang")
+}
+
+func (m *Module) String() string {
+	return "<module " + m.Name + ">"
+}
+
+// Member returns the member name. The natives of a standard library module
+// are returned as *Native for the engine to wrap.
+func (m *Module) Member(name string) (any, bool) {
+	return m.member(name)
+}
+
+var stdModules = map[string]*Module{}
+
+// defineModule registers a standard library module, which scripts import by
+// its bare name, as in `import "math";`. Natives among members are named
+// after the module, as in math.sqrt.
+func defineModule(name string, members map[string]any) {
+	for member, value := range members {
+		if native, ok := value.(*Native); ok {
+			native.Name = name + "." + member
+		}
+	}
+
+	stdModules[name] = NewModule(name, func(member string) (any, bool) {
+		value, ok := members[member]
+
+		return value, ok
+	})
+}
+
+// native makes a member function for defineModule.
+func native(arity int, fn Fn) *Native {
+	return &Native{
+		Arity: arity,
+		Fn:    fn,
+	}
+}
+
+// StdModule returns the standard library module called name.
+func StdModule(name string) (*Module, bool) {
+	module, ok := stdModules[name]
+
+	return module, ok
+}
+
+// Imports is an engine's record of the script modules it has loaded. Each
+// file is run once and its module reused by later imports; importing a file
+// that is still being run is an import cycle.
+type Imports struct {
+	modules map[string]*Module
+	loading []string
+}
+
+func NewImports() *Imports {
+	return &Imports{
+		modules: make(map[string]*Module),
+	}
+}
+
+// Loaded returns the module made from file, if it has been run.
+func (im *Imports) Loaded(file string) (*Module, bool) {
+	module, ok := im.modules[file]
+
+	return module, ok
+}
+
+// Begin records that file is about to run, failing if that would close a
+// cycle of imports.
+func (im *Imports) Begin(file string) error {
+	for i, loading := range im.loading {
+		if loading == file {
+			cycle := append(im.loading[i:len(im.loading):len(im.loading)], file)
+
+			return NewCodedError(diagnostic.CodeImport, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	im.loading = append(im.loading, file)
+
+	return nil
+}
+
+// End records that file has finished running. module is nil if it failed,
+// and a later import then runs the file again.
+func (im *Imports) End(file string, module *Module) {
+	im.loading = im.loading[:len(im.loading)-1]
+
+	if module != nil {
+		im.modules[file] = module
+	}
+}
@@ -0,0 +1,142 @@
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"internal/diagnostic"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testHost runs natives outside an engine. A callback is a Go function
+// standing in for a HOLang one.
+type testHost struct {
+	out bytes.Buffer
+}
+
+type callback func(arguments ...any) (any, error)
+
+func (h *testHost) Stdout() io.Writer {
+	return &h.out
+}
+
+func (h *testHost) Stdin() *bufio.Reader {
+	return bufio.NewReader(strings.NewReader(""))
+}
+
+func (h *testHost) Call(callee any, arguments ...any) (any, error) {
+	return callee.(callback)(arguments...)
+}
+
+func (h *testHost) Method(object any, name string) (any, bool) {
+	return nil, false
+}
+
+func (h *testHost) Fields(object any) (map[string]any, bool) {
+	return nil, false
+}
+
+// callTest calls a standard library function, named as in "math.abs", and
+// expects a result or an error with a message and code. A wantCode of ""
+// means diagnostic.CodeRuntime.
+type callTest struct {
+	call     string
+	args     []any
+	want     any
+	wantErr  string
+	wantCode diagnostic.Code
+}
+
+func callStd(t *testing.T, name string, arguments ...any) (any, error) {
+	t.Helper()
+
+	moduleName, member, _ := strings.Cut(name, ".")
+
+	module, ok := StdModule(moduleName)
+	if !ok {
+		t.Fatalf("no module %q", moduleName)
+	}
+
+	value, ok := module.Member(member)
+	if !ok {
+		t.Fatalf("no member %q", name)
+	}
+
+	native, ok := value.(*Native)
+	if !ok {
+		t.Fatalf("%s is %v, not a function", name, value)
+	}
+
+	if len(arguments) != native.Arity {
+		t.Fatalf("%s takes %d arguments, got %d", name, native.Arity, len(arguments))
+	}
+
+	return native.Fn(&testHost{}, arguments)
+}
+
+func runCallTests(t *testing.T, tests []callTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		got, err := callStd(t, tt.call, tt.args...)
+
+		if tt.wantErr != "" {
+			wantCode := tt.wantCode
+			if wantCode == "" {
+				wantCode = diagnostic.CodeRuntime
+			}
+
+			if err == nil || err.Error() != tt.wantErr || CodeOf(err) != wantCode {
+				t.Errorf("%s%v: got %v, error %v; want error %s %q", tt.call, tt.args, got, err, wantCode, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s%v: got %#v, error %v; want %#v", tt.call, tt.args, got, err, tt.want)
+		}
+	}
+}
+
+// callMethod calls a method of a built-in object, as receiver.name(arguments).
+func callMethod(t *testing.T, receiver Object, name string, arguments ...any) (any, error) {
+	t.Helper()
+
+	method, ok := receiver.Method(name)
+	if !ok {
+		t.Fatalf("%s has no method %q", receiver.TypeName(), name)
+	}
+
+	if len(arguments) != method.Arity {
+		t.Fatalf("%s takes %d arguments, got %d", name, method.Arity, len(arguments))
+	}
+
+	return method.Fn(&testHost{}, arguments)
+}
+
+// mustCall is callStd for calls that are expected to succeed.
+func mustCall(t *testing.T, name string, arguments ...any) any {
+	t.Helper()
+
+	value, err := callStd(t, name, arguments...)
+	if err != nil {
+		t.Fatalf("%s%v: %v", name, arguments, err)
+	}
+
+	return value
+}
+
+// checkFields checks the fields of a record against want.
+func checkFields(t *testing.T, record Record, want map[string]any) {
+	t.Helper()
+
+	for name, wantValue := range want {
+		got, ok := record.Field(name)
+		if !ok || !reflect.DeepEqual(got, wantValue) {
+			t.Errorf("%v.%s: got %#v, want %#v", record, name, got, wantValue)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"internal/diagnostic"
+	"io"
+)
+
+// MaxCallDepth bounds how deeply calls may nest in either engine, counting
+// the script being run and each module being imported as a call; a deeper
+// call is a stack overflow, which the program can catch.
+const MaxCallDepth = 1024
+
+// Host is the engine a native function runs in. Both the tree-walking
+// interpreter and the VM implement it.
+type Host interface {
+	Stdout() io.Writer
+	Stdin() *bufio.Reader
+
+	// Call runs a HOLang callable, such as the function passed to a list's
+	// map, and returns its result.
+	Call(callee any, arguments ...any) (any, error)
+
+	// Method returns the method name of a class instance bound to it, for
+	// natives that follow a protocol such as iter() and next().
+	Method(object any, name string) (any, bool)
+
+	// Fields returns the fields of a class instance, for natives that look
+	// inside objects such as json.stringify.
+	Fields(object any) (map[string]any, bool)
+}
+
+type Fn func(host Host, arguments []any) (any, error)
+
+// Native is a built-in function implemented in Go. Each engine wraps it in
+// its own callable value type.
+type Native struct {
+	Name  string
+	Arity int
+	Fn    Fn
+
+	// Receiver is the object a method such as a list's map is bound to. Fn
+	// holds it too, but the VM's collector can only trace it from here.
+	Receiver any
+}
+
+func (n *Native) String() string {
+	return "<native fn " + n.Name + ">"
+}
+
+// Error is returned by natives for failures the engine should surface as a
+// HOLang runtime error. Code is empty for plain runtime errors.
+type Error struct {
+	Message string
+	Code    diagnostic.Code
+}
+
+func NewError(format string, a ...any) *Error {
+	return &Error{
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func NewCodedError(code diagnostic.Code, format string, a ...any) *Error {
+	return &Error{
+		Message: fmt.Sprintf(format, a...),
+		Code:    code,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// CodeOf returns the code an engine reports a native's error with.
+func CodeOf(err error) diagnostic.Code {
+	if e, ok := err.(*Error); ok && e.Code != "" {
+		return e.Code
+	}
+
+	return diagnostic.CodeRuntime
+}
+
+var globals []*Native
+
+func define(name string, arity int, fn Fn) {
+	globals = append(globals, &Native{
+		Name:  name,
+		Arity: arity,
+		Fn:    fn,
+	})
+}
+
+// Globals returns the natives every program starts with, in definition order.
+func Globals() []*Native {
+	return globals
+}
@@ -0,0 +1,99 @@
+package builtin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Object is a built-in value with methods of its own, such as a list. The
+// engines look its methods up when a program reads a property of it.
+type Object interface {
+	TypeName() string
+	Method(name string) (*Native, bool)
+}
+
+// Record is an Object that also has read-only fields, such as the year of a
+// date. A field is looked up before a method of the same name.
+type Record interface {
+	Object
+	Field(name string) (any, bool)
+}
+
+type method[T Object] struct {
+	arity int
+	fn    func(host Host, receiver T, arguments []any) (any, error)
+}
+
+// bindMethod looks up name in methods and returns it bound to receiver.
+func bindMethod[T Object](methods map[string]method[T], receiver T, name string) (*Native, bool) {
+	m, ok := methods[name]
+	if !ok {
+		return nil, false
+	}
+
+	return &Native{
+		Name:  name,
+		Arity: m.arity,
+		Fn: func(host Host, arguments []any) (any, error) {
+			return m.fn(host, receiver, arguments)
+		},
+		Receiver: receiver,
+	}, true
+}
+
+// formatElement formats value as it appears inside a printed list or map.
+func formatElement(value any) string {
+	var builder strings.Builder
+	writeElement(&builder, value, make(map[any]bool))
+
+	return builder.String()
+}
+
+// writeElement formats value as it appears inside a printed list or map:
+// strings are quoted, and a container inside itself is shown as [...] or
+// {...}. open holds the containers being written.
+func writeElement(builder *strings.Builder, value any, open map[any]bool) {
+	switch v := value.(type) {
+	case string:
+		builder.WriteString(strconv.Quote(v))
+	case nil:
+		builder.WriteString("nil")
+	case *List:
+		if open[v] {
+			builder.WriteString("[...]")
+			return
+		}
+
+		open[v] = true
+		builder.WriteString("[")
+		for i, element := range v.Elements {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			writeElement(builder, element, open)
+		}
+		builder.WriteString("]")
+		delete(open, v)
+	case *Map:
+		if open[v] {
+			builder.WriteString("{...}")
+			return
+		}
+
+		open[v] = true
+		builder.WriteString("{")
+		for i, key := range v.keys {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			writeElement(builder, key, open)
+			builder.WriteString(": ")
+			writeElement(builder, v.values[key], open)
+		}
+		builder.WriteString("}")
+		delete(open, v)
+	default:
+		fmt.Fprint(builder, v)
+	}
+}
@@ -0,0 +1,98 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"os"
+)
+
+// The os module: the arguments given to the script, environment variables
+// and ending the process.
+func init() {
+	defineModule("os", map[string]any{
+		"args":   osArgs,
+		"env":    native(1, osEnv),
+		"setEnv": native(2, osSetEnv),
+		"exit":   native(1, osExit),
+	})
+}
+
+// osArgs is the same list for the whole run, so a script that changes it
+// sees its changes wherever it imports os.
+var osArgs = NewList(nil)
+
+// SetArgs sets os.args, the arguments that follow the script on the command
+// line.
+func SetArgs(args []string) {
+	osArgs.Elements = make([]any, len(args))
+	for i, arg := range args {
+		osArgs.Elements[i] = arg
+	}
+}
+
+var exit = os.Exit
+
+// SetExit lets the program decide how os.exit ends the process, for
+// instance to flush its logs first. By default it calls os.Exit.
+func SetExit(fn func(code int)) {
+	exit = fn
+}
+
+// osEnv returns the value of an environment variable, or nil if it is not
+// set.
+func osEnv(host Host, arguments []any) (any, error) {
+	name, err := toStr("env", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, nil
+	}
+
+	return value, nil
+}
+
+// osSetEnv sets an environment variable, or unsets it if the value is nil.
+// Processes the script starts later inherit it.
+func osSetEnv(host Host, arguments []any) (any, error) {
+	name, err := toStr("setEnv", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if arguments[1] == nil {
+		err = os.Unsetenv(name)
+	} else if value, ok := arguments[1].(string); ok {
+		err = os.Setenv(name, value)
+	} else {
+		return nil, NewCodedError(diagnostic.CodeType, "setEnv: value must be a string or nil, not %s", describe(arguments[1]))
+	}
+
+	if err != nil {
+		return nil, NewError("setEnv: %v", err)
+	}
+
+	return nil, nil
+}
+
+// osExit ends the process at once with a status from 0 to 255, after the
+// output written so far is flushed. catch and finally blocks do not run.
+func osExit(host Host, arguments []any) (any, error) {
+	code, ok := arguments[0].(int64)
+	if !ok {
+		return nil, NewCodedError(diagnostic.CodeType, "exit: code must be an int, not %s", describe(arguments[0]))
+	}
+
+	if code < 0 || code > 255 {
+		return nil, NewError("exit: code must be between 0 and 255: %d", code)
+	}
+
+	if w, ok := host.Stdout().(interface{ Flush() error }); ok {
+		w.Flush()
+	}
+
+	exit(int(code))
+
+	return nil, nil
+}
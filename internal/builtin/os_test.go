@@ -0,0 +1,71 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOS(t *testing.T) {
+	t.Setenv("HOLANG_TEST_SET", "호랑이")
+	t.Setenv("HOLANG_TEST_UNSET", "x")
+	os.Unsetenv("HOLANG_TEST_UNSET")
+	t.Setenv("HOLANG_TEST_NEW", "x")
+	os.Unsetenv("HOLANG_TEST_NEW")
+
+	runCallTests(t, []callTest{
+		{call: "os.env", args: []any{"HOLANG_TEST_SET"}, want: "호랑이"},
+		{call: "os.env", args: []any{"HOLANG_TEST_UNSET"}, want: nil},
+		{call: "os.env", args: []any{int64(1)}, wantErr: "env: argument must be a string, not a number", wantCode: diagnostic.CodeType},
+
+		{call: "os.setEnv", args: []any{"HOLANG_TEST_NEW", ""}, want: nil},
+		{call: "os.env", args: []any{"HOLANG_TEST_NEW"}, want: ""},
+		{call: "os.setEnv", args: []any{"HOLANG_TEST_SET", nil}, want: nil},
+		{call: "os.env", args: []any{"HOLANG_TEST_SET"}, want: nil},
+		{call: "os.setEnv", args: []any{"HOLANG_TEST_NEW", int64(1)}, wantErr: "setEnv: value must be a string or nil, not a number", wantCode: diagnostic.CodeType},
+		{call: "os.setEnv", args: []any{"", "x"}, wantErr: "setEnv: setenv: invalid argument"},
+
+		{call: "os.exit", args: []any{int64(256)}, wantErr: "exit: code must be between 0 and 255: 256"},
+		{call: "os.exit", args: []any{int64(-1)}, wantErr: "exit: code must be between 0 and 255: -1"},
+		{call: "os.exit", args: []any{1.0}, wantErr: "exit: code must be an int, not a number", wantCode: diagnostic.CodeType},
+	})
+}
+
+func TestOS_Args(t *testing.T) {
+	t.Cleanup(func() { SetArgs(nil) })
+
+	module, _ := StdModule("os")
+	args, _ := module.Member("args")
+
+	SetArgs([]string{"--level", "3", "맵.txt"})
+	if want := list("--level", "3", "맵.txt"); !reflect.DeepEqual(args, want) {
+		t.Errorf("args: got %v, want %v", args, want)
+	}
+
+	// the list os.args names stays the same, so every import sees a change
+	SetArgs(nil)
+	if again, _ := module.Member("args"); again != args || len(args.(*List).Elements) != 0 {
+		t.Errorf("args after SetArgs(nil): got %v", again)
+	}
+}
+
+func TestOS_Exit(t *testing.T) {
+	var codes []int
+	SetExit(func(code int) { codes = append(codes, code) })
+	t.Cleanup(func() { SetExit(os.Exit) })
+
+	for _, code := range []int64{0, 3, 255} {
+		if _, err := callStd(t, "os.exit", code); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := callStd(t, "os.exit", int64(256)); err == nil {
+		t.Fatal("exit(256) did not fail")
+	}
+
+	if want := []int{0, 3, 255}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("exit codes: got %v, want %v", codes, want)
+	}
+}
@@ -0,0 +1,261 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The re module, Go's RE2 syntax: matching takes time linear in the text,
+// and there are no backreferences. Match positions count characters (runes),
+// as substring does.
+func init() {
+	defineModule("re", map[string]any{
+		"compile": native(1, reCompile),
+	})
+}
+
+func reCompile(host Host, arguments []any) (any, error) {
+	pattern, err := toStr("compile", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, NewError("compile: %v", err)
+	}
+
+	return &Regex{re: re}, nil
+}
+
+// Regex is a compiled pattern.
+type Regex struct {
+	re *regexp.Regexp
+}
+
+func (r *Regex) TypeName() string {
+	return "regex"
+}
+
+func (r *Regex) String() string {
+	return "<regex " + r.re.String() + ">"
+}
+
+func (r *Regex) Field(name string) (any, bool) {
+	if name == "pattern" {
+		return r.re.String(), true
+	}
+
+	return nil, false
+}
+
+var regexMethods = map[string]method[*Regex]{
+	"test":    {1, regexTest},
+	"find":    {1, regexFind},
+	"findAll": {1, regexFindAll},
+	"groups":  {1, regexGroups},
+	"replace": {2, regexReplace},
+	"split":   {1, regexSplit},
+}
+
+func (r *Regex) Method(name string) (*Native, bool) {
+	return bindMethod(regexMethods, r, name)
+}
+
+func regexTest(host Host, r *Regex, arguments []any) (any, error) {
+	s, err := toStr("test", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return r.re.MatchString(s), nil
+}
+
+// regexFind returns the first match, or nil.
+func regexFind(host Host, r *Regex, arguments []any) (any, error) {
+	s, err := toStr("find", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	loc := r.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+
+	return r.match(s, loc, newRuneCounter(s)), nil
+}
+
+// regexFindAll returns every match that does not overlap an earlier one.
+func regexFindAll(host Host, r *Regex, arguments []any) (any, error) {
+	s, err := toStr("findAll", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	runes := newRuneCounter(s)
+
+	var matches []any
+	for _, loc := range r.re.FindAllStringSubmatchIndex(s, -1) {
+		matches = append(matches, r.match(s, loc, runes))
+	}
+
+	return NewList(matches), nil
+}
+
+// regexGroups returns the groups of the first match, or nil.
+func regexGroups(host Host, r *Regex, arguments []any) (any, error) {
+	s, err := toStr("groups", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	loc := r.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+
+	return NewList(r.match(s, loc, newRuneCounter(s)).groups), nil
+}
+
+// regexReplace replaces every match with a template, in which $1 or ${name}
+// stands for a group, or with what a function returns for the match.
+func regexReplace(host Host, r *Regex, arguments []any) (any, error) {
+	s, err := toStr("replace", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if template, ok := arguments[1].(string); ok {
+		return r.re.ReplaceAllString(s, template), nil
+	}
+
+	runes := newRuneCounter(s)
+
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range r.re.FindAllStringSubmatchIndex(s, -1) {
+		replacement, err := host.Call(arguments[1], r.match(s, loc, runes))
+		if err != nil {
+			return nil, err
+		}
+
+		text, ok := replacement.(string)
+		if !ok {
+			return nil, NewCodedError(diagnostic.CodeType, "replace: function must return a string, not %s", describe(replacement))
+		}
+
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(text)
+		last = loc[1]
+	}
+
+	b.WriteString(s[last:])
+
+	return b.String(), nil
+}
+
+func regexSplit(host Host, r *Regex, arguments []any) (any, error) {
+	s, err := toStr("split", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	parts := r.re.Split(s, -1)
+
+	list := make([]any, len(parts))
+	for i, part := range parts {
+		list[i] = part
+	}
+
+	return NewList(list), nil
+}
+
+// match makes the match found at loc, the byte offsets regexp reports.
+func (r *Regex) match(s string, loc []int, runes func(offset int) int) *Match {
+	m := &Match{
+		text:  s[loc[0]:loc[1]],
+		start: int64(runes(loc[0])),
+		end:   int64(runes(loc[1])),
+		names: r.re.SubexpNames()[1:],
+	}
+
+	for i := 2; i < len(loc); i += 2 {
+		if loc[i] < 0 {
+			m.groups = append(m.groups, nil)
+		} else {
+			m.groups = append(m.groups, s[loc[i]:loc[i+1]])
+		}
+	}
+
+	return m
+}
+
+// newRuneCounter returns a function converting byte offsets into s to rune
+// offsets. It counts on from the previous offset, so offsets that mostly
+// grow, as the matches of findAll do, are converted in one pass over s.
+func newRuneCounter(s string) func(offset int) int {
+	byteOffset, runeOffset := 0, 0
+
+	return func(offset int) int {
+		if offset < byteOffset {
+			byteOffset, runeOffset = 0, 0
+		}
+
+		runeOffset += utf8.RuneCountInString(s[byteOffset:offset])
+		byteOffset = offset
+
+		return runeOffset
+	}
+}
+
+// Match is a piece of text a regex matched. start and end are character
+// positions, end exclusive; groups lists what each group matched, nil for a
+// group that took no part, and named maps the named groups the same way.
+type Match struct {
+	text   string
+	start  int64
+	end    int64
+	groups []any
+	names  []string
+}
+
+func (m *Match) TypeName() string {
+	return "match"
+}
+
+func (m *Match) String() string {
+	return "<match " + m.text + ">"
+}
+
+func (m *Match) Field(name string) (any, bool) {
+	switch name {
+	case "text":
+		return m.text, true
+	case "start":
+		return m.start, true
+	case "end":
+		return m.end, true
+	case "groups":
+		// a fresh list each time, so changing one does not change the match
+		return NewList(append([]any(nil), m.groups...)), true
+	case "named":
+		named := NewMap()
+		for i, groupName := range m.names {
+			if groupName != "" {
+				named.Set(groupName, m.groups[i])
+			}
+		}
+
+		return named, true
+	}
+
+	return nil, false
+}
+
+func (m *Match) Method(name string) (*Native, bool) {
+	return nil, false
+}
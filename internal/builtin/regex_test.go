@@ -0,0 +1,141 @@
+package builtin
+
+import (
+	"errors"
+	"internal/diagnostic"
+	"reflect"
+	"testing"
+)
+
+// matchFields is what a match holds, for comparing matches.
+func matchFields(m *Match) map[string]any {
+	fields := make(map[string]any)
+	for _, name := range []string{"text", "start", "end", "groups", "named"} {
+		fields[name], _ = m.Field(name)
+	}
+
+	return fields
+}
+
+func TestRegex(t *testing.T) {
+	runCallTests(t, []callTest{
+		{call: "re.compile", args: []any{"a(b"}, wantErr: "compile: error parsing regexp: missing closing ): `a(b`"},
+		{call: "re.compile", args: []any{`(a)\1`}, wantErr: "compile: error parsing regexp: invalid escape sequence: `\\1`"},
+		{call: "re.compile", args: []any{nil}, wantErr: "compile: argument must be a string, not nil", wantCode: diagnostic.CodeType},
+	})
+
+	word := mustCall(t, "re.compile", `(?P<name>\pL+)(\d)?`).(*Regex)
+	digits := mustCall(t, "re.compile", `\d+`).(*Regex)
+	empty := mustCall(t, "re.compile", `x*`).(*Regex)
+
+	if pattern, _ := word.Field("pattern"); pattern != `(?P<name>\pL+)(\d)?` {
+		t.Errorf("pattern: got %v", pattern)
+	}
+
+	bracket := callback(func(arguments ...any) (any, error) {
+		text, _ := arguments[0].(*Match).Field("text")
+		return "<" + text.(string) + ">", nil
+	})
+
+	tests := []struct {
+		receiver *Regex
+		method   string
+		args     []any
+		want     any
+		wantErr  string
+	}{
+		{receiver: digits, method: "test", args: []any{"ab12"}, want: true},
+		{receiver: digits, method: "test", args: []any{"ab"}, want: false},
+		{receiver: digits, method: "find", args: []any{"ab"}, want: nil},
+		{receiver: digits, method: "groups", args: []any{"ab"}, want: nil},
+		{receiver: word, method: "groups", args: []any{"-- 호랑이7"}, want: list("호랑이", "7")},
+		{receiver: word, method: "groups", args: []any{"tiger"}, want: list("tiger", nil)},
+		{receiver: digits, method: "findAll", args: []any{"none"}, want: NewList(nil)},
+		{receiver: digits, method: "replace", args: []any{"a1b22", "#"}, want: "a#b#"},
+		{receiver: word, method: "replace", args: []any{"ab1 cd", "${name}=$2;"}, want: "ab=1; cd=;"},
+		{receiver: digits, method: "replace", args: []any{"가1나22", bracket}, want: "가<1>나<22>"},
+		{receiver: empty, method: "replace", args: []any{"가나", "-"}, want: "-가-나-"},
+		{receiver: digits, method: "split", args: []any{"a1b22c"}, want: list("a", "b", "c")},
+		{receiver: digits, method: "split", args: []any{"12"}, want: list("", "")},
+		{receiver: empty, method: "split", args: []any{"가나"}, want: list("가", "나")},
+
+		{receiver: digits, method: "replace", args: []any{"1", callback(func(arguments ...any) (any, error) { return int64(1), nil })},
+			wantErr: "replace: function must return a string, not a number"},
+		{receiver: digits, method: "replace", args: []any{"1", callback(func(arguments ...any) (any, error) { return nil, errors.New("boom") })},
+			wantErr: "boom"},
+		{receiver: digits, method: "test", args: []any{int64(1)}, wantErr: "test: argument must be a string, not a number"},
+	}
+
+	for _, tt := range tests {
+		got, err := callMethod(t, tt.receiver, tt.method, tt.args...)
+
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s%v: got %v, error %v; want error %q", tt.method, tt.args, got, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s%v: got %#v, error %v; want %#v", tt.method, tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestRegex_Matches(t *testing.T) {
+	word := mustCall(t, "re.compile", `(?P<name>\pL+)(\d)?`).(*Regex)
+	empty := mustCall(t, "re.compile", `x*`).(*Regex)
+
+	found, err := callMethod(t, word, "find", "-- 호랑이7 cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"text": "호랑이7", "start": int64(3), "end": int64(7),
+		"groups": list("호랑이", "7"), "named": mapOf("name", "호랑이")}
+	if got := matchFields(found.(*Match)); !reflect.DeepEqual(got, want) {
+		t.Errorf("find: got %v, want %v", got, want)
+	}
+
+	all, err := callMethod(t, word, "findAll", "-- 호랑이7 cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotAll []map[string]any
+	for _, m := range all.(*List).Elements {
+		gotAll = append(gotAll, matchFields(m.(*Match)))
+	}
+
+	wantAll := []map[string]any{
+		want,
+		{"text": "cat", "start": int64(8), "end": int64(11), "groups": list("cat", nil), "named": mapOf("name", "cat")},
+	}
+	if !reflect.DeepEqual(gotAll, wantAll) {
+		t.Errorf("findAll: got %v, want %v", gotAll, wantAll)
+	}
+
+	// an empty pattern matches between every character, counted in runes
+	all, err = callMethod(t, empty, "findAll", "가나")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var starts []int64
+	for _, m := range all.(*List).Elements {
+		start, _ := m.(*Match).Field("start")
+		starts = append(starts, start.(int64))
+	}
+
+	if !reflect.DeepEqual(starts, []int64{0, 1, 2}) {
+		t.Errorf("findAll of an empty pattern: got starts %v, want [0 1 2]", starts)
+	}
+
+	// groups is a copy each time it is read
+	groups, _ := found.(*Match).Field("groups")
+	groups.(*List).Elements[0] = "changed"
+	if again, _ := found.(*Match).Field("groups"); !reflect.DeepEqual(again, list("호랑이", "7")) {
+		t.Errorf("changing groups changed the match: %v", again)
+	}
+}
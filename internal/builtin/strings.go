@@ -0,0 +1,257 @@
+package builtin
+
+import (
+	"fmt"
+	"internal/diagnostic"
+	"strings"
+	"unicode/utf8"
+)
+
+// The strings module. Positions, lengths and widths count characters
+// (runes), as substring does, not bytes.
+func init() {
+	defineModule("strings", map[string]any{
+		"split":      native(2, stringsSplit),
+		"join":       native(2, stringsJoin),
+		"trim":       native(1, stringFn("trim", strings.TrimSpace)),
+		"upper":      native(1, stringFn("upper", strings.ToUpper)),
+		"lower":      native(1, stringFn("lower", strings.ToLower)),
+		"replace":    native(3, stringsReplace),
+		"indexOf":    native(2, stringsIndexOf),
+		"startsWith": native(2, stringsStartsWith),
+		"endsWith":   native(2, stringsEndsWith),
+		"repeat":     native(2, stringsRepeat),
+		"padLeft":    native(3, padFn("padLeft", true)),
+		"padRight":   native(3, padFn("padRight", false)),
+		"chars":      native(1, stringsChars),
+		"format":     native(2, stringsFormat),
+	})
+}
+
+// toStr reads a string argument of the function name.
+func toStr(name string, value any) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", NewCodedError(diagnostic.CodeType, "%s: argument must be a string, not %s", name, describe(value))
+	}
+
+	return s, nil
+}
+
+// strArgs reads the leading string arguments of the function name.
+func strArgs(name string, arguments []any, n int) ([]string, error) {
+	strs := make([]string, n)
+
+	for i := range strs {
+		s, err := toStr(name, arguments[i])
+		if err != nil {
+			return nil, err
+		}
+
+		strs[i] = s
+	}
+
+	return strs, nil
+}
+
+// count reads a non-negative int argument of the function name.
+func count(name string, value any) (int, error) {
+	n, ok := value.(int64)
+	if !ok {
+		return 0, NewCodedError(diagnostic.CodeType, "%s: count must be an int, not %s", name, describe(value))
+	}
+
+	if n < 0 {
+		return 0, NewError("%s: count must not be negative: %d", name, n)
+	}
+
+	return int(n), nil
+}
+
+func stringFn(name string, fn func(string) string) Fn {
+	return func(host Host, arguments []any) (any, error) {
+		s, err := toStr(name, arguments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(s), nil
+	}
+}
+
+// stringsSplit splits s around each sep; an empty sep splits it into
+// characters.
+func stringsSplit(host Host, arguments []any) (any, error) {
+	args, err := strArgs("split", arguments, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(args[0], args[1])
+
+	elements := make([]any, len(parts))
+	for i, part := range parts {
+		elements[i] = part
+	}
+
+	return NewList(elements), nil
+}
+
+// stringsJoin concatenates the elements of a list, converted as str does,
+// with sep between them.
+func stringsJoin(host Host, arguments []any) (any, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, NewCodedError(diagnostic.CodeType, "join: argument must be a list, not %s", describe(arguments[0]))
+	}
+
+	return listJoin(host, list, arguments[1:])
+}
+
+func stringsReplace(host Host, arguments []any) (any, error) {
+	args, err := strArgs("replace", arguments, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.ReplaceAll(args[0], args[1], args[2]), nil
+}
+
+// stringsIndexOf returns the character position of the first sub in s, or -1.
+func stringsIndexOf(host Host, arguments []any) (any, error) {
+	args, err := strArgs("indexOf", arguments, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.Index(args[0], args[1])
+	if i < 0 {
+		return int64(-1), nil
+	}
+
+	return int64(utf8.RuneCountInString(args[0][:i])), nil
+}
+
+func stringsStartsWith(host Host, arguments []any) (any, error) {
+	args, err := strArgs("startsWith", arguments, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasPrefix(args[0], args[1]), nil
+}
+
+func stringsEndsWith(host Host, arguments []any) (any, error) {
+	args, err := strArgs("endsWith", arguments, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasSuffix(args[0], args[1]), nil
+}
+
+func stringsRepeat(host Host, arguments []any) (any, error) {
+	s, err := toStr("repeat", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := count("repeat", arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Repeat(s, n), nil
+}
+
+// padFn makes padLeft or padRight, which add the one-character fill to one
+// side of s until it is width characters long.
+func padFn(name string, left bool) Fn {
+	return func(host Host, arguments []any) (any, error) {
+		s, err := toStr(name, arguments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		width, err := count(name, arguments[1])
+		if err != nil {
+			return nil, err
+		}
+
+		fill, err := toStr(name, arguments[2])
+		if err != nil {
+			return nil, err
+		}
+
+		if utf8.RuneCountInString(fill) != 1 {
+			return nil, NewError("%s: fill must be one character, not %q", name, fill)
+		}
+
+		padding := strings.Repeat(fill, max(width-utf8.RuneCountInString(s), 0))
+		if left {
+			return padding + s, nil
+		}
+
+		return s + padding, nil
+	}
+}
+
+func stringsChars(host Host, arguments []any) (any, error) {
+	s, err := toStr("chars", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	chars := make([]any, 0, len(s))
+	for _, r := range s {
+		chars = append(chars, string(r))
+	}
+
+	return NewList(chars), nil
+}
+
+// stringsFormat replaces each {} in template with the next element of a
+// list, converted as str does. {{ and }} stand for literal braces.
+func stringsFormat(host Host, arguments []any) (any, error) {
+	template, err := toStr("format", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := arguments[1].(*List)
+	if !ok {
+		return nil, NewCodedError(diagnostic.CodeType, "format: values must be a list, not %s", describe(arguments[1]))
+	}
+
+	var builder strings.Builder
+	next := 0
+
+	for i := 0; i < len(template); i++ {
+		switch {
+		case strings.HasPrefix(template[i:], "{{"), strings.HasPrefix(template[i:], "}}"):
+			builder.WriteByte(template[i])
+			i++
+
+		case strings.HasPrefix(template[i:], "{}"):
+			if next >= len(values.Elements) {
+				return nil, NewError("format: template has more {} than the %d values given", len(values.Elements))
+			}
+
+			builder.WriteString(fmt.Sprint(values.Elements[next]))
+			next++
+			i++
+
+		case template[i] == '{' || template[i] == '}':
+			return nil, NewError("format: unmatched %q in template; write {{ or }} for a brace", template[i])
+
+		default:
+			builder.WriteByte(template[i])
+		}
+	}
+
+	if next < len(values.Elements) {
+		return nil, NewError("format: %d values given but the template has only %d {}", len(values.Elements), next)
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,54 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"testing"
+)
+
+func list(elements ...any) *List {
+	return NewList(elements)
+}
+
+func TestStrings(t *testing.T) {
+	runCallTests(t, []callTest{
+		{call: "strings.split", args: []any{"a,b,,c", ","}, want: list("a", "b", "", "c")},
+		{call: "strings.split", args: []any{"한글a", ""}, want: list("한", "글", "a")},
+		{call: "strings.split", args: []any{"", ","}, want: list("")},
+		{call: "strings.join", args: []any{list("a", int64(1), 2.5, nil), "-"}, want: "a-1-2.5-<nil>"},
+		{call: "strings.join", args: []any{"ab", ""}, wantErr: "join: argument must be a list, not a string", wantCode: diagnostic.CodeType},
+
+		{call: "strings.trim", args: []any{" \t hi \n"}, want: "hi"},
+		{call: "strings.upper", args: []any{"abc"}, want: "ABC"},
+		{call: "strings.lower", args: []any{"ABC"}, want: "abc"},
+		{call: "strings.upper", args: []any{int64(1)}, wantErr: "upper: argument must be a string, not a number", wantCode: diagnostic.CodeType},
+
+		{call: "strings.replace", args: []any{"a-b-c", "-", "+"}, want: "a+b+c"},
+		{call: "strings.replace", args: []any{"가나", "", "."}, want: ".가.나."},
+		{call: "strings.replace", args: []any{"abc", "x", "y"}, want: "abc"},
+
+		{call: "strings.indexOf", args: []any{"가나다", "다"}, want: int64(2)},
+		{call: "strings.indexOf", args: []any{"abc", "z"}, want: int64(-1)},
+		{call: "strings.indexOf", args: []any{"abc", ""}, want: int64(0)},
+		{call: "strings.startsWith", args: []any{"holang", "ho"}, want: true},
+		{call: "strings.endsWith", args: []any{"holang", "ho"}, want: false},
+
+		{call: "strings.repeat", args: []any{"ab", int64(3)}, want: "ababab"},
+		{call: "strings.repeat", args: []any{"ab", int64(0)}, want: ""},
+		{call: "strings.repeat", args: []any{"ab", int64(-1)}, wantErr: "repeat: count must not be negative: -1"},
+		{call: "strings.repeat", args: []any{"ab", 1.5}, wantErr: "repeat: count must be an int, not a number", wantCode: diagnostic.CodeType},
+
+		{call: "strings.padLeft", args: []any{"7", int64(3), "0"}, want: "007"},
+		{call: "strings.padLeft", args: []any{"한글", int64(3), "*"}, want: "*한글"},
+		{call: "strings.padRight", args: []any{"abcd", int64(2), " "}, want: "abcd"},
+		{call: "strings.padRight", args: []any{"a", int64(3), "ab"}, wantErr: `padRight: fill must be one character, not "ab"`},
+
+		{call: "strings.chars", args: []any{"hé!"}, want: list("h", "é", "!")},
+		{call: "strings.chars", args: []any{""}, want: NewList([]any{})},
+
+		{call: "strings.format", args: []any{"{}님 {}점", list("호랭", int64(100))}, want: "호랭님 100점"},
+		{call: "strings.format", args: []any{"{{}} {}", list(true)}, want: "{} true"},
+		{call: "strings.format", args: []any{"{} {}", list(int64(1))}, wantErr: "format: template has more {} than the 1 values given"},
+		{call: "strings.format", args: []any{"{}", list(int64(1), int64(2))}, wantErr: "format: 2 values given but the template has only 1 {}"},
+		{call: "strings.format", args: []any{"a } b", list()}, wantErr: "format: unmatched '}' in template; write {{ or }} for a brace"},
+	})
+}
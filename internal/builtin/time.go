@@ -0,0 +1,241 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"math"
+	"time"
+	_ "time/tzdata" // zones work the same on machines without a zone database
+)
+
+// The time module. Clocks and durations count milliseconds as ints, except
+// nanos for finer timing. millis and nanos are monotonic: they only measure
+// time elapsed since the program started and never jump with the wall clock.
+// Layouts are Go's, written as the reference time Mon Jan 2 15:04:05 MST 2006
+// would look, as in "2006-01-02 15:04". Zones are IANA names such as
+// "Asia/Seoul", "UTC" or "Local"; nil means the local zone.
+func init() {
+	defineModule("time", map[string]any{
+		"millisecond": int64(1),
+		"second":      int64(time.Second / time.Millisecond),
+		"minute":      int64(time.Minute / time.Millisecond),
+		"hour":        int64(time.Hour / time.Millisecond),
+		"iso":         time.RFC3339,
+
+		"millis":         native(0, timeMillis),
+		"nanos":          native(0, timeNanos),
+		"now":            native(0, timeNow),
+		"unixMillis":     native(0, timeUnixMillis),
+		"fromUnixMillis": native(2, timeFromUnixMillis),
+		"parse":          native(3, timeParse),
+		"duration":       native(1, timeDuration),
+	})
+}
+
+var started = time.Now()
+
+func timeMillis(host Host, arguments []any) (any, error) {
+	return time.Since(started).Milliseconds(), nil
+}
+
+func timeNanos(host Host, arguments []any) (any, error) {
+	return int64(time.Since(started)), nil
+}
+
+func timeNow(host Host, arguments []any) (any, error) {
+	return &Date{time: time.Now()}, nil
+}
+
+func timeUnixMillis(host Host, arguments []any) (any, error) {
+	return time.Now().UnixMilli(), nil
+}
+
+// timeFromUnixMillis returns the date a number of milliseconds after the
+// Unix epoch, in a zone.
+func timeFromUnixMillis(host Host, arguments []any) (any, error) {
+	ms, err := toMillis("fromUnixMillis", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := toZone("fromUnixMillis", arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Date{time: time.UnixMilli(ms).In(zone)}, nil
+}
+
+// timeParse reads a date written in a layout. A zone in the text wins over
+// the zone argument, which is used when the layout has none.
+func timeParse(host Host, arguments []any) (any, error) {
+	strs, err := strArgs("parse", arguments, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := toZone("parse", arguments[2])
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.ParseInLocation(strs[1], strs[0], zone)
+	if err != nil {
+		return nil, NewError("parse: %v", err)
+	}
+
+	return &Date{time: t}, nil
+}
+
+// timeDuration reads a duration such as "1h30m" or "250ms" as milliseconds.
+func timeDuration(host Host, arguments []any) (any, error) {
+	s, err := toStr("duration", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, NewError("duration: invalid duration %q", s)
+	}
+
+	return d.Milliseconds(), nil
+}
+
+// toMillis reads an int argument of the function name counting milliseconds.
+func toMillis(name string, value any) (int64, error) {
+	ms, ok := value.(int64)
+	if !ok {
+		return 0, NewCodedError(diagnostic.CodeType, "%s: milliseconds must be an int, not %s", name, describe(value))
+	}
+
+	return ms, nil
+}
+
+// toZone reads a zone argument of the function name.
+func toZone(name string, value any) (*time.Location, error) {
+	if value == nil {
+		return time.Local, nil
+	}
+
+	s, err := toStr(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, NewError("%s: unknown time zone %q", name, s)
+	}
+
+	return zone, nil
+}
+
+// Date is an instant as seen in one zone. Its fields are the calendar and
+// clock readings there: month runs from 1 and weekday from 0 for Sunday.
+type Date struct {
+	time time.Time
+}
+
+func (d *Date) TypeName() string {
+	return "date"
+}
+
+func (d *Date) String() string {
+	return "<date " + d.time.Format(time.RFC3339Nano) + ">"
+}
+
+func (d *Date) Field(name string) (any, bool) {
+	t := d.time
+
+	switch name {
+	case "year":
+		return int64(t.Year()), true
+	case "month":
+		return int64(t.Month()), true
+	case "day":
+		return int64(t.Day()), true
+	case "hour":
+		return int64(t.Hour()), true
+	case "minute":
+		return int64(t.Minute()), true
+	case "second":
+		return int64(t.Second()), true
+	case "millisecond":
+		return int64(t.Nanosecond() / int(time.Millisecond)), true
+	case "weekday":
+		return int64(t.Weekday()), true
+	case "yearDay":
+		return int64(t.YearDay()), true
+	case "zone":
+		if name := t.Location().String(); name != "" {
+			return name, true
+		}
+
+		abbreviation, _ := t.Zone()
+
+		return abbreviation, true
+	}
+
+	return nil, false
+}
+
+var dateMethods = map[string]method[*Date]{
+	"format":     {1, dateFormat},
+	"add":        {1, dateAdd},
+	"sub":        {1, dateSub},
+	"inZone":     {1, dateInZone},
+	"unixMillis": {0, dateUnixMillis},
+}
+
+func (d *Date) Method(name string) (*Native, bool) {
+	return bindMethod(dateMethods, d, name)
+}
+
+func dateFormat(host Host, d *Date, arguments []any) (any, error) {
+	layout, err := toStr("format", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return d.time.Format(layout), nil
+}
+
+// dateAdd returns the date a number of milliseconds later, or earlier if it
+// is negative.
+func dateAdd(host Host, d *Date, arguments []any) (any, error) {
+	ms, err := toMillis("add", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// a time.Duration holds about 292 years
+	if ms > math.MaxInt64/int64(time.Millisecond) || ms < math.MinInt64/int64(time.Millisecond) {
+		return nil, NewError("add: %d milliseconds is too long", ms)
+	}
+
+	return &Date{time: d.time.Add(time.Duration(ms) * time.Millisecond)}, nil
+}
+
+// dateSub returns the milliseconds from another date to this one.
+func dateSub(host Host, d *Date, arguments []any) (any, error) {
+	other, ok := arguments[0].(*Date)
+	if !ok {
+		return nil, NewCodedError(diagnostic.CodeType, "sub: argument must be a date, not %s", describe(arguments[0]))
+	}
+
+	return d.time.Sub(other.time).Milliseconds(), nil
+}
+
+// dateInZone returns the same instant as seen in another zone.
+func dateInZone(host Host, d *Date, arguments []any) (any, error) {
+	zone, err := toZone("inZone", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Date{time: d.time.In(zone)}, nil
+}
+
+func dateUnixMillis(host Host, d *Date, arguments []any) (any, error) {
+	return d.time.UnixMilli(), nil
+}
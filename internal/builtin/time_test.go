@@ -0,0 +1,130 @@
+package builtin
+
+import (
+	"internal/diagnostic"
+	"testing"
+)
+
+func TestTime(t *testing.T) {
+	runCallTests(t, []callTest{
+		{call: "time.duration", args: []any{"1h30m"}, want: int64(90 * 60 * 1000)},
+		{call: "time.duration", args: []any{"250ms"}, want: int64(250)},
+		{call: "time.duration", args: []any{"-1.5s"}, want: int64(-1500)},
+		{call: "time.duration", args: []any{"1500us"}, want: int64(1)},
+		{call: "time.duration", args: []any{"10"}, wantErr: `duration: invalid duration "10"`},
+		{call: "time.duration", args: []any{int64(10)}, wantErr: "duration: argument must be a string, not a number", wantCode: diagnostic.CodeType},
+
+		{call: "time.fromUnixMillis", args: []any{1.5, "UTC"}, wantErr: "fromUnixMillis: milliseconds must be an int, not a number", wantCode: diagnostic.CodeType},
+		{call: "time.fromUnixMillis", args: []any{int64(0), "Mars/Olympus"}, wantErr: `fromUnixMillis: unknown time zone "Mars/Olympus"`},
+		{call: "time.parse", args: []any{"2024-13-01", "2006-01-02", "UTC"}, wantErr: `parse: parsing time "2024-13-01": month out of range`},
+		{call: "time.parse", args: []any{"2024-01-01", int64(1), "UTC"}, wantErr: "parse: argument must be a string, not a number", wantCode: diagnostic.CodeType},
+	})
+}
+
+func TestTime_Dates(t *testing.T) {
+	tests := []struct {
+		name   string
+		date   any
+		fields map[string]any
+		iso    string
+	}{
+		{
+			name: "fromUnixMillis in UTC",
+			date: mustCall(t, "time.fromUnixMillis", int64(1_700_000_000_123), "UTC"),
+			fields: map[string]any{"year": int64(2023), "month": int64(11), "day": int64(14), "hour": int64(22),
+				"minute": int64(13), "second": int64(20), "millisecond": int64(123), "weekday": int64(2),
+				"yearDay": int64(318), "zone": "UTC"},
+			iso: "2023-11-14T22:13:20Z",
+		},
+		{
+			name:   "fromUnixMillis before the epoch",
+			date:   mustCall(t, "time.fromUnixMillis", int64(-1), "UTC"),
+			fields: map[string]any{"year": int64(1969), "month": int64(12), "day": int64(31), "millisecond": int64(999)},
+			iso:    "1969-12-31T23:59:59Z",
+		},
+		{
+			name:   "fromUnixMillis in a zone",
+			date:   mustCall(t, "time.fromUnixMillis", int64(0), "Asia/Seoul"),
+			fields: map[string]any{"hour": int64(9), "weekday": int64(4), "zone": "Asia/Seoul"},
+			iso:    "1970-01-01T09:00:00+09:00",
+		},
+		{
+			name:   "parse uses the zone argument",
+			date:   mustCall(t, "time.parse", "2024-02-29 07:05", "2006-01-02 15:04", "Asia/Seoul"),
+			fields: map[string]any{"month": int64(2), "day": int64(29), "yearDay": int64(60), "zone": "Asia/Seoul"},
+			iso:    "2024-02-29T07:05:00+09:00",
+		},
+		{
+			name:   "a zone in the text wins",
+			date:   mustCall(t, "time.parse", "2024-03-01T12:00:00+02:00", "2006-01-02T15:04:05Z07:00", "UTC"),
+			fields: map[string]any{"hour": int64(12)},
+			iso:    "2024-03-01T12:00:00+02:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.date.(*Date)
+			checkFields(t, d, tt.fields)
+
+			if got, err := callMethod(t, d, "format", "2006-01-02T15:04:05Z07:00"); err != nil || got != tt.iso {
+				t.Errorf("format: got %v, error %v; want %q", got, err, tt.iso)
+			}
+		})
+	}
+}
+
+func TestDate_Methods(t *testing.T) {
+	start := mustCall(t, "time.fromUnixMillis", int64(1_000), "UTC").(*Date)
+
+	later, err := callMethod(t, start, "add", int64(90*60*1000+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkFields(t, later.(*Date), map[string]any{"hour": int64(1), "minute": int64(30), "second": int64(1), "millisecond": int64(1)})
+
+	tests := []struct {
+		receiver *Date
+		method   string
+		args     []any
+		want     any
+		wantErr  string
+	}{
+		{receiver: later.(*Date), method: "sub", args: []any{start}, want: int64(90*60*1000 + 1)},
+		{receiver: start, method: "sub", args: []any{later}, want: int64(-(90*60*1000 + 1))},
+		{receiver: later.(*Date), method: "unixMillis", want: int64(90*60*1000 + 1001)},
+		{receiver: start, method: "format", args: []any{"Jan 2 15:04:05.000"}, want: "Jan 1 00:00:01.000"},
+		{receiver: start, method: "sub", args: []any{int64(1)}, wantErr: "sub: argument must be a date, not a number"},
+		{receiver: start, method: "add", args: []any{"1s"}, wantErr: "add: milliseconds must be an int, not a string"},
+		{receiver: start, method: "add", args: []any{int64(1) << 62}, wantErr: "add: 4611686018427387904 milliseconds is too long"},
+		{receiver: start, method: "inZone", args: []any{"Nowhere"}, wantErr: `inZone: unknown time zone "Nowhere"`},
+	}
+
+	for _, tt := range tests {
+		got, err := callMethod(t, tt.receiver, tt.method, tt.args...)
+
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s%v: got %v, error %v; want error %q", tt.method, tt.args, got, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("%s%v: got %#v, error %v; want %#v", tt.method, tt.args, got, err, tt.want)
+		}
+	}
+
+	seoul, err := callMethod(t, start, "inZone", "Asia/Seoul")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkFields(t, seoul.(*Date), map[string]any{"hour": int64(9), "zone": "Asia/Seoul"})
+
+	if got, _ := callMethod(t, seoul.(*Date), "sub", start); got != int64(0) {
+		t.Errorf("the same instant in another zone is %v ms away", got)
+	}
+}
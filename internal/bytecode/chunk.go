@@ -4,19 +4,41 @@ import (
 	"encoding/binary"
 	"fmt"
 	"internal/util/log"
+	"strconv"
 )
 
 type Value any
 
+// JumpOperandWidth is the encoded size of a jump operand. The target of a
+// forward jump is unknown when the jump is emitted, so its operand is written
+// as a varint padded to this fixed width and overwritten in place later.
+const JumpOperandWidth = binary.MaxVarintLen32
+
 type Offset struct {
-	Line  int
-	Index int
+	Line   int
+	Column int
+	Index  int
+	Length int
+}
+
+// Handler is an entry of a chunk's exception table. An error raised by an
+// instruction in [Start, End) cuts the frame's stack back to Depth slots,
+// pushes the thrown value and continues at Target. A Finally handler gets
+// the pending error itself instead, for OP_RETHROW to raise again unchanged.
+// Entries are searched in order, so inner handlers come first.
+type Handler struct {
+	Start   int
+	End     int
+	Target  int
+	Depth   int
+	Finally bool
 }
 
 type Chunk struct {
 	code      []byte
 	constants []Value
 	offsets   []Offset
+	handlers  []Handler
 }
 
 func NewChunk() *Chunk {
@@ -37,6 +59,38 @@ func (c *Chunk) AddOperator(offset Offset, op OpCode, operands ...int64) {
 	}
 }
 
+// AddJump appends a jump operator with a fixed-width operand and returns the
+// position of the operand so it can be patched with PatchJump.
+func (c *Chunk) AddJump(offset Offset, op OpCode, operand int64) int {
+	c.AddCode(op)
+	c.offsets = append(c.offsets, offset)
+
+	at := len(c.code)
+	c.code = append(c.code, make([]byte, JumpOperandWidth)...)
+	c.PatchJump(at, operand)
+
+	return at
+}
+
+// PatchJump overwrites the fixed-width operand written by AddJump.
+func (c *Chunk) PatchJump(at int, operand int64) {
+	ux := uint64(operand) << 1
+	if operand < 0 {
+		ux = ^ux
+	}
+
+	if ux >= 1<<(7*JumpOperandWidth) {
+		log.Fatal("jump operand too large", log.I64("operand", operand))
+	}
+
+	for i := range JumpOperandWidth - 1 {
+		c.code[at+i] = byte(ux) | 0x80
+		ux >>= 7
+	}
+
+	c.code[at+JumpOperandWidth-1] = byte(ux)
+}
+
 func (c *Chunk) AddCode(code ...any) {
 	for _, v := range code {
 		switch v := v.(type) {
@@ -72,42 +126,153 @@ func (c *Chunk) GetOperand(index int) (int64, int) {
 	return binary.Varint(c.code[index:])
 }
 
+// OffsetAt returns the source offset of the instruction containing the byte
+// at pos, or the zero Offset if pos is outside the code.
+func (c *Chunk) OffsetAt(pos int) Offset {
+	for start, opIdx := 0, 0; start < len(c.code) && opIdx < len(c.offsets); opIdx++ {
+		next := start + 1
+		for range OpCode(c.code[start]).OperandsCount() {
+			_, n := c.GetOperand(next)
+			next += max(n, 1)
+		}
+
+		if pos >= start && pos < next {
+			return c.offsets[opIdx]
+		}
+
+		start = next
+	}
+
+	return Offset{}
+}
+
+func (c *Chunk) AddHandler(handler Handler) {
+	c.handlers = append(c.handlers, handler)
+}
+
+// HandlerAt returns the first handler protecting the instruction containing
+// the byte at pos.
+func (c *Chunk) HandlerAt(pos int) (Handler, bool) {
+	for _, handler := range c.handlers {
+		if pos >= handler.Start && pos < handler.End {
+			return handler, true
+		}
+	}
+
+	return Handler{}, false
+}
+
 func (c *Chunk) Clear() {
 	c.code = c.code[:0]
 	c.constants = c.constants[:0]
+	c.offsets = c.offsets[:0]
+	c.handlers = c.handlers[:0]
 }
 
 func (c *Chunk) Size() int {
 	return len(c.code)
 }
 
+// Disassemble returns one line per instruction of this chunk, such as
+// "0004  L12  OP_GET_GLOBAL  3 'dan'", then one per exception handler.
+// Nested functions are not expanded; see DisassembleAll.
 func (c *Chunk) Disassemble() []string {
 	var dis []string
 
-	opIdx := 0
-	for pos := 0; pos < len(c.code); pos++ {
-		operator := OpCode(c.code[pos])
-		_pos := pos
+	for pos, opIdx := 0, 0; pos < len(c.code); opIdx++ {
+		var lines []string
+		lines, pos = c.disassembleInstruction(pos, opIdx)
+		dis = append(dis, lines...)
+	}
+
+	for _, handler := range c.handlers {
+		line := fmt.Sprintf("handler  %04d-%04d -> %04d  depth %d", handler.Start, handler.End, handler.Target, handler.Depth)
+		if handler.Finally {
+			line += "  finally"
+		}
+
+		dis = append(dis, line)
+	}
+
+	return dis
+}
 
-		operandsCount := operator.OperandsCount()
-		operands := make([]any, operandsCount)
+// DisassembleAll disassembles the chunk under a "== name ==" header,
+// followed by every function nested in it.
+func (c *Chunk) DisassembleAll(name string) []string {
+	dis := append([]string{"== " + name + " =="}, c.Disassemble()...)
 
-		for j := range operandsCount {
-			x, n := c.GetOperand(pos + 1 + j)
-			pos += n
+	for _, constant := range c.constants {
+		if function, ok := constant.(*Function); ok {
+			dis = append(dis, "")
+			dis = append(dis, function.Chunk.DisassembleAll(function.String())...)
+		}
+	}
+
+	return dis
+}
+
+func (c *Chunk) disassembleInstruction(pos, opIdx int) ([]string, int) {
+	start := pos
+	operator := OpCode(c.code[pos])
+	pos++
+
+	line := "L-"
+	if opIdx < len(c.offsets) && c.offsets[opIdx].Line > 0 {
+		line = fmt.Sprintf("L%d", c.offsets[opIdx].Line)
+	}
 
-			if operator == OP_CONSTANT {
-				operands[j] = fmt.Sprintf("%d, value %v", x, c.GetConstant(x))
-			} else {
-				operands[j] = x
+	text := fmt.Sprintf("%04d  %s  %s", start, line, operator)
+	var constant Value
+
+	for range operator.OperandsCount() {
+		if pos >= len(c.code) {
+			return []string{text + "  <truncated>"}, pos
+		}
+
+		x, n := c.GetOperand(pos)
+		pos += n
+
+		switch {
+		case operator == OP_JUMP || operator == OP_JUMP_IF_FALSE || operator == OP_FOR_ITER:
+			text += fmt.Sprintf("  %d -> %04d", x, pos+int(x))
+		case operator == OP_LOOP:
+			text += fmt.Sprintf("  %d -> %04d", x, pos-int(x))
+		case constantOperands[operator] != nil && x >= 0 && x < int64(len(c.constants)):
+			constant = c.constants[x]
+			text += fmt.Sprintf("  %d %s", x, formatConstant(constant))
+		default:
+			text += fmt.Sprintf("  %d", x)
+		}
+	}
+
+	lines := []string{text}
+
+	// OP_CLOSURE captures are described by the function, not by operands.
+	if function, ok := constant.(*Function); ok && operator == OP_CLOSURE {
+		for _, upvalue := range function.Upvalues {
+			kind := "upvalue"
+			if upvalue.IsLocal {
+				kind = "local"
 			}
+
+			lines = append(lines, fmt.Sprintf("%04d     |  %s %d", start, kind, upvalue.Index))
 		}
+	}
 
-		dis = append(dis, operator.String())
-		log.Debug("Disassemble", log.I("pos", _pos), log.A("offset", c.offsets[opIdx]), log.A("operator", operator), log.A("operands", operands))
+	return lines, pos
+}
 
-		opIdx += 1
+// formatConstant formats a constant for the disassembly. Strings are quoted
+// and escaped, so one with a newline or a quote still takes a single line.
+func formatConstant(value Value) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
 	}
 
-	return dis
+	if value == nil {
+		return "nil"
+	}
+
+	return fmt.Sprint(value)
 }
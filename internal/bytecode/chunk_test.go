@@ -0,0 +1,71 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunk_PatchJumpKeepsFixedWidth(t *testing.T) {
+	c := NewChunk()
+
+	at := c.AddJump(Offset{}, OP_JUMP, 0)
+	c.AddOperator(Offset{}, OP_POP)
+
+	for _, want := range []int64{0, 1, -1, 63, 64, 300, -300, 1 << 20} {
+		c.PatchJump(at, want)
+
+		got, n := c.GetOperand(at)
+		if got != want || n != JumpOperandWidth {
+			t.Fatalf("patch %d: got %d (width %d) want %d (width %d)", want, got, n, want, JumpOperandWidth)
+		}
+
+		if op := c.GetOperator(at + JumpOperandWidth); op != OP_POP {
+			t.Fatalf("patch %d clobbered next instruction: got %s", want, op)
+		}
+	}
+}
+
+func TestChunk_DisassembleFormatsOperands(t *testing.T) {
+	c := sampleChunk()
+
+	want := []string{
+		"== <script> ==",
+		"0000  L1  OP_CONSTANT  0 -7",
+		"0002  L1  OP_CONSTANT  1 2.5",
+		`0004  L1  OP_CONSTANT  2 "héllo"`,
+		"0006  L2  OP_CLOSURE  5 <fn add>",
+		"0006     |  local 1",
+		"0008  L3  OP_JUMP_IF_FALSE  1 -> 0015",
+		"0014  L3  OP_POP",
+		"0015  L-  OP_NIL",
+		"0016  L-  OP_RETURN",
+		"handler  0000-0006 -> 0014  depth 1",
+		"handler  0000-0008 -> 0015  depth 1  finally",
+		"",
+		"== <fn add> ==",
+		"0000  L2  OP_GET_LOCAL  1",
+		"0002  L2  OP_GET_UPVALUE  0",
+		"0004  L2  OP_ADD",
+		"0005  L2  OP_RETURN",
+	}
+
+	got := c.DisassembleAll("<script>")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("disassembly:\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestChunk_DisassembleKeepsStringConstantsOnOneLine(t *testing.T) {
+	c := NewChunk()
+	c.AddOperator(Offset{Line: 1}, OP_CONSTANT, c.AddConstant("line\n\"quoted\"\t'"))
+	c.AddOperator(Offset{Line: 1}, OP_RETURN)
+
+	want := []string{
+		`0000  L1  OP_CONSTANT  0 "line\n\"quoted\"\t'"`,
+		"0002  L1  OP_RETURN",
+	}
+
+	if got := c.Disassemble(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("disassembly:\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
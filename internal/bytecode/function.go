@@ -0,0 +1,33 @@
+package bytecode
+
+// Function is a compiled function body. The top-level script is compiled
+// into a Function with an empty name.
+type Function struct {
+	Name     string
+	Arity    int
+	Chunk    *Chunk
+	Upvalues []UpvalueInfo
+}
+
+// UpvalueInfo tells OP_CLOSURE where to capture an upvalue from: a local
+// slot of the enclosing frame, or one of the enclosing closure's upvalues.
+type UpvalueInfo struct {
+	IsLocal bool
+	Index   int
+}
+
+func NewFunction(name string, arity int) *Function {
+	return &Function{
+		Name:  name,
+		Arity: arity,
+		Chunk: NewChunk(),
+	}
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+
+	return "<fn " + f.Name + ">"
+}
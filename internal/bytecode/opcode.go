@@ -46,11 +46,48 @@ const (
 	OP_DEFINE_GLOBAL
 	OP_GET_GLOBAL
 	OP_SET_GLOBAL
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_UPVALUE
+	OP_SET_UPVALUE
+
+	// JUMP
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+
+	// FUNCTION
+	OP_CALL
+	OP_CLOSURE
+	OP_CLOSE_UPVALUE
+
+	// CLASS
+	OP_CLASS
+	OP_METHOD
+	OP_INHERIT
+	OP_GET_PROPERTY
+	OP_SET_PROPERTY
+	OP_GET_SUPER
 
 	// SPECIAL
 	OP_RETURN
 	OP_POP
 	OP_PRINT
+
+	// EXCEPTION
+	OP_THROW
+	OP_RETHROW
+
+	// COLLECTION
+	OP_LIST
+	OP_MAP
+	OP_GET_INDEX
+	OP_SET_INDEX
+	OP_ITER
+	OP_FOR_ITER
+
+	// MODULE
+	OP_IMPORT
 )
 
 var operandsCount = map[OpCode]int{
@@ -58,6 +95,24 @@ var operandsCount = map[OpCode]int{
 	OP_DEFINE_GLOBAL: 1,
 	OP_GET_GLOBAL:    1,
 	OP_SET_GLOBAL:    1,
+	OP_GET_LOCAL:     1,
+	OP_SET_LOCAL:     1,
+	OP_GET_UPVALUE:   1,
+	OP_SET_UPVALUE:   1,
+	OP_JUMP:          1,
+	OP_JUMP_IF_FALSE: 1,
+	OP_LOOP:          1,
+	OP_CALL:          1,
+	OP_CLOSURE:       1,
+	OP_CLASS:         1,
+	OP_METHOD:        1,
+	OP_GET_PROPERTY:  1,
+	OP_SET_PROPERTY:  1,
+	OP_GET_SUPER:     1,
+	OP_LIST:          1,
+	OP_MAP:           1,
+	OP_FOR_ITER:      1,
+	OP_IMPORT:        1,
 }
 
 func (op OpCode) OperandsCount() int {
@@ -67,3 +122,8 @@ func (op OpCode) OperandsCount() int {
 
 	return 0
 }
+
+// IsValid reports whether op is a defined operator.
+func (op OpCode) IsValid() bool {
+	return int(op) < len(_OpCode_index)-1
+}
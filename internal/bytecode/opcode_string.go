@@ -34,14 +34,39 @@ func _() {
 	_ = x[OP_DEFINE_GLOBAL-23]
 	_ = x[OP_GET_GLOBAL-24]
 	_ = x[OP_SET_GLOBAL-25]
-	_ = x[OP_RETURN-26]
-	_ = x[OP_POP-27]
-	_ = x[OP_PRINT-28]
+	_ = x[OP_GET_LOCAL-26]
+	_ = x[OP_SET_LOCAL-27]
+	_ = x[OP_GET_UPVALUE-28]
+	_ = x[OP_SET_UPVALUE-29]
+	_ = x[OP_JUMP-30]
+	_ = x[OP_JUMP_IF_FALSE-31]
+	_ = x[OP_LOOP-32]
+	_ = x[OP_CALL-33]
+	_ = x[OP_CLOSURE-34]
+	_ = x[OP_CLOSE_UPVALUE-35]
+	_ = x[OP_CLASS-36]
+	_ = x[OP_METHOD-37]
+	_ = x[OP_INHERIT-38]
+	_ = x[OP_GET_PROPERTY-39]
+	_ = x[OP_SET_PROPERTY-40]
+	_ = x[OP_GET_SUPER-41]
+	_ = x[OP_RETURN-42]
+	_ = x[OP_POP-43]
+	_ = x[OP_PRINT-44]
+	_ = x[OP_THROW-45]
+	_ = x[OP_RETHROW-46]
+	_ = x[OP_LIST-47]
+	_ = x[OP_MAP-48]
+	_ = x[OP_GET_INDEX-49]
+	_ = x[OP_SET_INDEX-50]
+	_ = x[OP_ITER-51]
+	_ = x[OP_FOR_ITER-52]
+	_ = x[OP_IMPORT-53]
 }
 
-const _OpCode_name = "OP_CONSTANTOP_TRUEOP_FALSEOP_NILOP_CONSTANT_M1OP_CONSTANT_0OP_CONSTANT_1OP_CONSTANT_2OP_CONSTANT_3OP_CONSTANT_4OP_CONSTANT_5OP_NEGATEOP_NOTOP_ADDOP_SUBTRACTOP_MULTIPLYOP_DIVIDEOP_EQUALOP_NOT_EQUALOP_GREATEROP_LESSOP_GREATER_EQUALOP_LESS_EQUALOP_DEFINE_GLOBALOP_GET_GLOBALOP_SET_GLOBALOP_RETURNOP_POPOP_PRINT"
+const _OpCode_name = "OP_CONSTANTOP_TRUEOP_FALSEOP_NILOP_CONSTANT_M1OP_CONSTANT_0OP_CONSTANT_1OP_CONSTANT_2OP_CONSTANT_3OP_CONSTANT_4OP_CONSTANT_5OP_NEGATEOP_NOTOP_ADDOP_SUBTRACTOP_MULTIPLYOP_DIVIDEOP_EQUALOP_NOT_EQUALOP_GREATEROP_LESSOP_GREATER_EQUALOP_LESS_EQUALOP_DEFINE_GLOBALOP_GET_GLOBALOP_SET_GLOBALOP_GET_LOCALOP_SET_LOCALOP_GET_UPVALUEOP_SET_UPVALUEOP_JUMPOP_JUMP_IF_FALSEOP_LOOPOP_CALLOP_CLOSUREOP_CLOSE_UPVALUEOP_CLASSOP_METHODOP_INHERITOP_GET_PROPERTYOP_SET_PROPERTYOP_GET_SUPEROP_RETURNOP_POPOP_PRINTOP_THROWOP_RETHROWOP_LISTOP_MAPOP_GET_INDEXOP_SET_INDEXOP_ITEROP_FOR_ITEROP_IMPORT"
 
-var _OpCode_index = [...]uint16{0, 11, 18, 26, 32, 46, 59, 72, 85, 98, 111, 124, 133, 139, 145, 156, 167, 176, 184, 196, 206, 213, 229, 242, 258, 271, 284, 293, 299, 307}
+var _OpCode_index = [...]uint16{0, 11, 18, 26, 32, 46, 59, 72, 85, 98, 111, 124, 133, 139, 145, 156, 167, 176, 184, 196, 206, 213, 229, 242, 258, 271, 284, 296, 308, 322, 336, 343, 359, 366, 373, 383, 399, 407, 416, 426, 441, 456, 468, 477, 483, 491, 499, 509, 516, 522, 534, 546, 553, 564, 573}
 
 func (i OpCode) String() string {
 	if i >= OpCode(len(_OpCode_index)-1) {
@@ -0,0 +1,404 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+// A .hoc file is a compiled script:
+//
+//	magic "\x7fHOC" | version (uint16 LE) | script chunk | CRC-32 of everything before (uint32 LE)
+//
+// A chunk is its code (uvarint length + bytes), its constant pool (uvarint
+// count + tagged values), its line table (uvarint count + one varint
+// line/column/index/length span per instruction) and its exception table
+// (uvarint count + uvarint start/end/target/depth and a finally flag byte per
+// handler).
+//
+// Function constants carry their name, arity, upvalue descriptors and a
+// nested chunk.
+const (
+	// FormatVersion changes whenever the layout changes or opcodes are
+	// renumbered, as OP_MAP did by joining the collection opcodes.
+	FormatVersion = 4
+
+	// maxFunctionDepth bounds how deeply function constants may nest, so a
+	// crafted file cannot exhaust the Go stack while decoding.
+	maxFunctionDepth = 256
+)
+
+var formatMagic = [4]byte{0x7f, 'H', 'O', 'C'}
+
+const (
+	tagNil byte = iota
+	tagFalse
+	tagTrue
+	tagInt
+	tagFloat
+	tagString
+	tagFunction
+)
+
+// ErrInvalidFile is wrapped by every error Decode returns.
+var ErrInvalidFile = errors.New("invalid .hoc file")
+
+// IsCompiled reports whether data starts with the .hoc magic header.
+func IsCompiled(data []byte) bool {
+	return len(data) >= len(formatMagic) && [4]byte(data[:4]) == formatMagic
+}
+
+// Encode serializes a compiled script chunk into the .hoc format.
+func Encode(chunk *Chunk) ([]byte, error) {
+	w := &encoder{buf: append([]byte{}, formatMagic[:]...)}
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, FormatVersion)
+
+	if err := w.chunk(chunk); err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(w.buf)), nil
+}
+
+// Decode reads a .hoc file and verifies every chunk in it before returning
+// the script chunk.
+func Decode(data []byte) (*Chunk, error) {
+	if !IsCompiled(data) {
+		return nil, fmt.Errorf("%w: missing magic header", ErrInvalidFile)
+	}
+
+	if len(data) < len(formatMagic)+2+4 {
+		return nil, fmt.Errorf("%w: truncated header", ErrInvalidFile)
+	}
+
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidFile)
+	}
+
+	if version := binary.LittleEndian.Uint16(body[4:]); version != FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d (want %d)", ErrInvalidFile, version, FormatVersion)
+	}
+
+	r := &decoder{buf: body, pos: len(formatMagic) + 2}
+
+	chunk := r.chunk(0)
+	if r.err == nil && r.pos != len(r.buf) {
+		r.fail("%d trailing bytes", len(r.buf)-r.pos)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := Verify(chunk); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	return chunk, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (w *encoder) uvarint(v int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v))
+}
+
+func (w *encoder) varint(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *encoder) bool(b bool) {
+	if b {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *encoder) string(s string) {
+	w.uvarint(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *encoder) chunk(c *Chunk) error {
+	w.uvarint(len(c.code))
+	w.buf = append(w.buf, c.code...)
+
+	w.uvarint(len(c.constants))
+	for _, constant := range c.constants {
+		if err := w.value(constant); err != nil {
+			return err
+		}
+	}
+
+	w.uvarint(len(c.offsets))
+	for _, offset := range c.offsets {
+		w.varint(int64(offset.Line))
+		w.varint(int64(offset.Column))
+		w.varint(int64(offset.Index))
+		w.varint(int64(offset.Length))
+	}
+
+	w.uvarint(len(c.handlers))
+	for _, handler := range c.handlers {
+		w.uvarint(handler.Start)
+		w.uvarint(handler.End)
+		w.uvarint(handler.Target)
+		w.uvarint(handler.Depth)
+		w.bool(handler.Finally)
+	}
+
+	return nil
+}
+
+func (w *encoder) value(v Value) error {
+	switch v := v.(type) {
+	case nil:
+		w.buf = append(w.buf, tagNil)
+	case bool:
+		if v {
+			w.buf = append(w.buf, tagTrue)
+		} else {
+			w.buf = append(w.buf, tagFalse)
+		}
+	case int64:
+		w.buf = append(w.buf, tagInt)
+		w.varint(v)
+	case float64:
+		w.buf = append(w.buf, tagFloat)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+	case string:
+		w.buf = append(w.buf, tagString)
+		w.string(v)
+	case *Function:
+		w.buf = append(w.buf, tagFunction)
+		w.string(v.Name)
+		w.uvarint(v.Arity)
+		w.uvarint(len(v.Upvalues))
+		for _, upvalue := range v.Upvalues {
+			w.bool(upvalue.IsLocal)
+			w.uvarint(upvalue.Index)
+		}
+
+		return w.chunk(v.Chunk)
+	default:
+		return fmt.Errorf("cannot serialize constant of type %T", v)
+	}
+
+	return nil
+}
+
+// decoder reads from buf and remembers the first error; every read after a
+// failure returns a zero value so callers only check err at the end.
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *decoder) fail(format string, a ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: at byte %d: %s", ErrInvalidFile, r.pos, fmt.Sprintf(format, a...))
+	}
+}
+
+func (r *decoder) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+
+	if r.pos >= len(r.buf) {
+		r.fail("unexpected end of file")
+
+		return 0
+	}
+
+	b := r.buf[r.pos]
+	r.pos++
+
+	return b
+}
+
+func (r *decoder) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n > len(r.buf)-r.pos {
+		r.fail("unexpected end of file")
+
+		return nil
+	}
+
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+
+	return b
+}
+
+// length reads a count and rejects it if even one byte per element would
+// run past the end of the file.
+func (r *decoder) length() int {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.fail("malformed length")
+
+		return 0
+	}
+
+	if v > uint64(len(r.buf)-r.pos-n) {
+		r.fail("length %d exceeds file size", v)
+
+		return 0
+	}
+
+	r.pos += n
+
+	return int(v)
+}
+
+func (r *decoder) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 || v > math.MaxInt32 {
+		r.fail("malformed uvarint")
+
+		return 0
+	}
+
+	r.pos += n
+
+	return int(v)
+}
+
+func (r *decoder) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 {
+		r.fail("malformed varint")
+
+		return 0
+	}
+
+	r.pos += n
+
+	return v
+}
+
+// bool reads a flag byte, failing on anything but 0 or 1.
+func (r *decoder) bool(what string) bool {
+	b := r.byte()
+	if b > 1 {
+		r.fail("malformed %s", what)
+	}
+
+	return b == 1
+}
+
+func (r *decoder) string() string {
+	return string(r.bytes(r.length()))
+}
+
+func (r *decoder) chunk(depth int) *Chunk {
+	c := NewChunk()
+	c.code = append([]byte{}, r.bytes(r.length())...)
+
+	count := r.length()
+	for range count {
+		if r.err != nil {
+			break
+		}
+
+		c.constants = append(c.constants, r.value(depth))
+	}
+
+	count = r.length()
+	for range count {
+		if r.err != nil {
+			break
+		}
+
+		c.offsets = append(c.offsets, Offset{
+			Line:   int(r.varint()),
+			Column: int(r.varint()),
+			Index:  int(r.varint()),
+			Length: int(r.varint()),
+		})
+	}
+
+	count = r.length()
+	for range count {
+		if r.err != nil {
+			break
+		}
+
+		c.handlers = append(c.handlers, Handler{
+			Start:   r.uvarint(),
+			End:     r.uvarint(),
+			Target:  r.uvarint(),
+			Depth:   r.uvarint(),
+			Finally: r.bool("handler"),
+		})
+	}
+
+	return c
+}
+
+func (r *decoder) value(depth int) Value {
+	switch tag := r.byte(); tag {
+	case tagNil:
+		return nil
+	case tagFalse:
+		return false
+	case tagTrue:
+		return true
+	case tagInt:
+		return r.varint()
+	case tagFloat:
+		b := r.bytes(8)
+		if b == nil {
+			return nil
+		}
+
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case tagString:
+		return r.string()
+	case tagFunction:
+		if depth >= maxFunctionDepth {
+			r.fail("functions nested deeper than %d", maxFunctionDepth)
+
+			return nil
+		}
+
+		function := &Function{Name: r.string(), Arity: r.uvarint()}
+
+		count := r.length()
+		for range count {
+			isLocal := r.bool("upvalue descriptor")
+			function.Upvalues = append(function.Upvalues, UpvalueInfo{IsLocal: isLocal, Index: r.uvarint()})
+		}
+
+		function.Chunk = r.chunk(depth + 1)
+
+		return function
+	default:
+		r.fail("unknown constant tag %d", tag)
+
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package bytecode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func sampleChunk() *Chunk {
+	inner := NewFunction("add", 2)
+	inner.Upvalues = []UpvalueInfo{{IsLocal: true, Index: 1}}
+	inner.Chunk.AddOperator(Offset{Line: 2, Index: 4}, OP_GET_LOCAL, 1)
+	inner.Chunk.AddOperator(Offset{Line: 2, Index: 8}, OP_GET_UPVALUE, 0)
+	inner.Chunk.AddOperator(Offset{Line: 2, Index: 6}, OP_ADD)
+	inner.Chunk.AddOperator(Offset{Line: 2, Index: 2}, OP_RETURN)
+
+	c := NewChunk()
+	c.AddOperator(Offset{Line: 1, Index: 0}, OP_CONSTANT, c.AddConstant(int64(-7)))
+	c.AddOperator(Offset{Line: 1, Index: 3}, OP_CONSTANT, c.AddConstant(2.5))
+	c.AddOperator(Offset{Line: 1, Index: 9}, OP_CONSTANT, c.AddConstant("héllo"))
+	c.AddConstant(nil)
+	c.AddConstant(true)
+	c.AddOperator(Offset{Line: 2, Index: 0}, OP_CLOSURE, c.AddConstant(inner))
+	at := c.AddJump(Offset{Line: 3, Index: 0}, OP_JUMP_IF_FALSE, 0)
+	c.AddOperator(Offset{Line: 3, Index: 5}, OP_POP)
+	c.PatchJump(at, int64(c.Size()-(at+JumpOperandWidth)))
+	c.AddOperator(Offset{Line: -1, Index: -1}, OP_NIL)
+	c.AddOperator(Offset{Line: -1, Index: -1}, OP_RETURN)
+	c.AddHandler(Handler{Start: 0, End: 6, Target: 14, Depth: 1})
+	c.AddHandler(Handler{Start: 0, End: 8, Target: 15, Depth: 1, Finally: true})
+
+	return c
+}
+
+func TestSerialize_RoundTrip(t *testing.T) {
+	want := sampleChunk()
+
+	data, err := Encode(want)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip changed the chunk:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSerialize_RejectsTruncatedAndTamperedFiles(t *testing.T) {
+	data, err := Encode(sampleChunk())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	for n := range len(data) {
+		if _, err := Decode(data[:n]); !errors.Is(err, ErrInvalidFile) {
+			t.Fatalf("truncated to %d bytes: got %v want ErrInvalidFile", n, err)
+		}
+	}
+
+	for i := range data {
+		tampered := append([]byte{}, data...)
+		tampered[i] ^= 0x40
+
+		if _, err := Decode(tampered); !errors.Is(err, ErrInvalidFile) {
+			t.Fatalf("flipped byte %d: got %v want ErrInvalidFile", i, err)
+		}
+	}
+}
+
+func TestVerify_RejectsMalformedCode(t *testing.T) {
+	cases := map[string]func(c *Chunk){
+		"unknown opcode":       func(c *Chunk) { c.code[0] = 0xff },
+		"constant range":       func(c *Chunk) { c.constants = c.constants[:1] },
+		"jump into operand":    func(c *Chunk) { c.PatchJump(c.Size()-3-JumpOperandWidth, -2) },
+		"missing line entry":   func(c *Chunk) { c.offsets = c.offsets[1:] },
+		"handler into operand": func(c *Chunk) { c.handlers[0].Target = 9 },
+		"empty handler range":  func(c *Chunk) { c.handlers[0].End = c.handlers[0].Start },
+		"missing final return": func(c *Chunk) { c.code = c.code[:len(c.code)-1]; c.offsets = c.offsets[:len(c.offsets)-1] },
+	}
+
+	for name, corrupt := range cases {
+		c := sampleChunk()
+		corrupt(c)
+
+		if err := Verify(c); err == nil {
+			t.Errorf("%s: verified a malformed chunk", name)
+		}
+	}
+}
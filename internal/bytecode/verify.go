@@ -0,0 +1,152 @@
+package bytecode
+
+import "fmt"
+
+// constantOperands lists the operators whose operand indexes the constant
+// pool, and the constant they expect there.
+var constantOperands = map[OpCode]func(Value) bool{
+	OP_CONSTANT:      func(Value) bool { return true },
+	OP_DEFINE_GLOBAL: isString,
+	OP_GET_GLOBAL:    isString,
+	OP_SET_GLOBAL:    isString,
+	OP_CLOSURE:       isFunction,
+	OP_CLASS:         isString,
+	OP_METHOD:        isString,
+	OP_GET_PROPERTY:  isString,
+	OP_SET_PROPERTY:  isString,
+	OP_GET_SUPER:     isString,
+	OP_IMPORT:        isString,
+}
+
+func isString(v Value) bool {
+	_, ok := v.(string)
+
+	return ok
+}
+
+func isFunction(v Value) bool {
+	_, ok := v.(*Function)
+
+	return ok
+}
+
+// Verify checks that a script chunk, and every function nested in it, is
+// well formed: known operators, complete operands, constant and upvalue
+// indices in range, jumps and exception handlers landing on instructions,
+// one line table entry per instruction and a final OP_RETURN. It does not
+// check stack discipline.
+func Verify(chunk *Chunk) error {
+	return verifyFunction(&Function{Chunk: chunk}, 0)
+}
+
+func verifyFunction(function *Function, enclosingUpvalues int) error {
+	for _, upvalue := range function.Upvalues {
+		if !upvalue.IsLocal && upvalue.Index >= enclosingUpvalues {
+			return fmt.Errorf("%s: captures upvalue %d of %d", function, upvalue.Index, enclosingUpvalues)
+		}
+	}
+
+	if err := verifyChunk(function); err != nil {
+		return fmt.Errorf("%s: %w", function, err)
+	}
+
+	for _, constant := range function.Chunk.constants {
+		if nested, ok := constant.(*Function); ok {
+			if err := verifyFunction(nested, len(function.Upvalues)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func verifyChunk(function *Function) error {
+	c := function.Chunk
+	starts := make(map[int]bool)
+	var jumps []struct{ at, target int }
+	var last OpCode
+
+	for pos := 0; pos < len(c.code); {
+		start := pos
+		op := OpCode(c.code[pos])
+		pos++
+
+		if !op.IsValid() {
+			return fmt.Errorf("unknown opcode %d at %d", op, start)
+		}
+
+		var operand int64
+		for range op.OperandsCount() {
+			if pos >= len(c.code) {
+				return fmt.Errorf("%s at %d: truncated operand", op, start)
+			}
+
+			v, n := c.GetOperand(pos)
+			if n <= 0 {
+				return fmt.Errorf("%s at %d: malformed operand", op, start)
+			}
+
+			operand = v
+			pos += n
+		}
+
+		if op.OperandsCount() > 0 && operand < 0 && op != OP_JUMP && op != OP_JUMP_IF_FALSE && op != OP_FOR_ITER && op != OP_LOOP {
+			return fmt.Errorf("%s at %d: negative operand %d", op, start, operand)
+		}
+
+		if accepts, ok := constantOperands[op]; ok {
+			if operand >= int64(len(c.constants)) {
+				return fmt.Errorf("%s at %d: constant %d out of range", op, start, operand)
+			}
+
+			if !accepts(c.constants[operand]) {
+				return fmt.Errorf("%s at %d: unexpected constant %v", op, start, c.constants[operand])
+			}
+		}
+
+		switch op {
+		case OP_GET_UPVALUE, OP_SET_UPVALUE:
+			if operand >= int64(len(function.Upvalues)) {
+				return fmt.Errorf("%s at %d: upvalue %d out of range", op, start, operand)
+			}
+		case OP_JUMP, OP_JUMP_IF_FALSE, OP_FOR_ITER:
+			jumps = append(jumps, struct{ at, target int }{start, pos + int(operand)})
+		case OP_LOOP:
+			jumps = append(jumps, struct{ at, target int }{start, pos - int(operand)})
+		}
+
+		starts[start] = true
+		last = op
+	}
+
+	if len(starts) != len(c.offsets) {
+		return fmt.Errorf("%d instructions but %d line table entries", len(starts), len(c.offsets))
+	}
+
+	if len(starts) == 0 || last != OP_RETURN {
+		return fmt.Errorf("does not end with %s", OP_RETURN)
+	}
+
+	for _, jump := range jumps {
+		if !starts[jump.target] {
+			return fmt.Errorf("jump at %d targets %d, which is not an instruction", jump.at, jump.target)
+		}
+	}
+
+	for i, handler := range c.handlers {
+		if handler.Start >= handler.End || !starts[handler.Start] || !(starts[handler.End] || handler.End == len(c.code)) {
+			return fmt.Errorf("handler %d covers %d-%d, which is not a run of instructions", i, handler.Start, handler.End)
+		}
+
+		if !starts[handler.Target] {
+			return fmt.Errorf("handler %d targets %d, which is not an instruction", i, handler.Target)
+		}
+
+		if handler.Depth < 1 {
+			return fmt.Errorf("handler %d keeps %d stack slots", i, handler.Depth)
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,8 @@ type Emitter interface {
 	EmitJump(offset bytecode.Offset, op bytecode.OpCode) int
 	PatchJump(at int)
 	EmitLoop(offset bytecode.Offset, loopStart int)
+	AddHandler(handler bytecode.Handler)
+	Size() int
 }
 
 type ChunkEmitter struct {
@@ -28,14 +30,30 @@ func (e *ChunkEmitter) MakeConstant(value bytecode.Value) int64 {
 	return e.chunk.AddConstant(value)
 }
 
+// EmitJump emits a forward jump with a placeholder operand and returns the
+// operand position to hand to PatchJump once the target is known.
 func (e *ChunkEmitter) EmitJump(offset bytecode.Offset, op bytecode.OpCode) int {
-	return 1
+	return e.chunk.AddJump(offset, op, 0)
 }
 
+// PatchJump makes the jump at `at` land on the next emitted instruction.
 func (e *ChunkEmitter) PatchJump(at int) {
+	jump := e.chunk.Size() - (at + bytecode.JumpOperandWidth)
 
+	e.chunk.PatchJump(at, int64(jump))
 }
 
+// EmitLoop emits a backward jump to loopStart.
 func (e *ChunkEmitter) EmitLoop(offset bytecode.Offset, loopStart int) {
+	back := e.chunk.Size() + 1 + bytecode.JumpOperandWidth - loopStart
 
+	e.chunk.AddJump(offset, bytecode.OP_LOOP, int64(back))
+}
+
+func (e *ChunkEmitter) AddHandler(handler bytecode.Handler) {
+	e.chunk.AddHandler(handler)
+}
+
+func (e *ChunkEmitter) Size() int {
+	return e.chunk.Size()
 }
@@ -0,0 +1,33 @@
+package codegen
+
+import (
+	"internal/ast"
+	"internal/diagnostic"
+)
+
+// CompileError is a static error found while generating bytecode.
+type CompileError struct {
+	Message string
+	Code    diagnostic.Code
+	Offset  ast.Offset
+}
+
+func newCompileError(code diagnostic.Code, message string, offset ast.Offset) *CompileError {
+	return &CompileError{
+		Message: message,
+		Code:    code,
+		Offset:  offset,
+	}
+}
+
+func (e *CompileError) Error() string {
+	return e.Message
+}
+
+func (e *CompileError) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{
+		Code:    e.Code,
+		Message: e.Message,
+		Span:    diagnostic.Span(e.Offset),
+	}
+}
@@ -1,19 +1,106 @@
 package codegen
 
 import (
-	"errors"
 	"internal/ast"
 	"internal/bytecode"
+	"internal/diagnostic"
 	"internal/scanner"
 )
 
 type CodeGenerator struct {
-	em Emitter
+	*funcState
+
+	classes []*classState
+}
+
+type functionType int
+
+const (
+	typeScript functionType = iota
+	typeFunction
+	typeMethod
+	typeInitializer
+)
+
+// classState describes the class whose methods are being generated.
+type classState struct {
+	hasSuperclass bool
+}
+
+// funcState is the compiler state of the function currently being generated.
+// Nested function declarations push a new state linked through enclosing.
+type funcState struct {
+	enclosing *funcState
+	function  *bytecode.Function
+	fnType    functionType
+
+	em    Emitter
+	loops []*loop
+	tries []*tryState
+
+	locals     []local
+	scopeDepth int
+}
+
+// local is a variable living in a stack slot; its slot is its index in locals.
+// A captured local is closed over by an inner function and has to be moved
+// off the stack by OP_CLOSE_UPVALUE instead of simply popped.
+type local struct {
+	name        string
+	depth       int
+	initialized bool
+	isCaptured  bool
+}
+
+// loop tracks the jumps of the innermost enclosing loop so break and
+// continue can be patched once the loop's exit and increment are emitted.
+type loop struct {
+	start         int
+	scopeDepth    int
+	tries         int // try statements already open when the loop started
+	breakJumps    []int
+	continueJumps []int
+}
+
+// tryState is a try statement whose body or catch block is being generated.
+// ranges collects the code its handlers protect. A jump out of the statement
+// runs the finally block inline, and that copy is left out of the ranges so
+// an error raised in it is not handled by the statement it is leaving.
+type tryState struct {
+	finally *ast.Block
+	start   int // start of the open range
+	ranges  [][2]int
+}
+
+func (t *tryState) open(at int) {
+	t.start = at
+}
+
+func (t *tryState) close(at int) {
+	if at > t.start {
+		t.ranges = append(t.ranges, [2]int{t.start, at})
+	}
 }
 
 func NewCodeGenerator(em Emitter) *CodeGenerator {
 	return &CodeGenerator{
-		em: em,
+		funcState: newFuncState(nil, nil, typeScript, em),
+	}
+}
+
+func newFuncState(enclosing *funcState, function *bytecode.Function, fnType functionType, em Emitter) *funcState {
+	// slot 0 holds the called function itself, or the receiver in methods
+	slotZero := local{name: "", depth: 0, initialized: true}
+	if fnType == typeMethod || fnType == typeInitializer {
+		slotZero.name = "this"
+	}
+
+	return &funcState{
+		enclosing: enclosing,
+		function:  function,
+		fnType:    fnType,
+		em:        em,
+		locals:    []local{slotZero},
 	}
 }
 
@@ -26,7 +113,7 @@ func (g *CodeGenerator) Generate(statements []ast.Stmt) error {
 		}
 	}
 
-	g.emit_(bytecode.OP_RETURN)
+	g.emitReturn()
 
 	return nil
 }
@@ -98,6 +185,180 @@ func (g *CodeGenerator) makeConstant(value bytecode.Value) int64 {
 	return g.em.MakeConstant(value)
 }
 
+// ================================================================
+// Scope
+// ================================================================
+
+func (g *CodeGenerator) beginScope() {
+	g.scopeDepth++
+}
+
+func (g *CodeGenerator) endScope(offset ast.Offset) {
+	g.scopeDepth--
+
+	for len(g.locals) > 0 && g.locals[len(g.locals)-1].depth > g.scopeDepth {
+		g.emitDiscardLocal(offset, g.locals[len(g.locals)-1])
+		g.locals = g.locals[:len(g.locals)-1]
+	}
+}
+
+func (g *CodeGenerator) emitDiscardLocal(offset ast.Offset, l local) {
+	if l.isCaptured {
+		g.emit(offset, bytecode.OP_CLOSE_UPVALUE)
+	} else {
+		g.emit(offset, bytecode.OP_POP)
+	}
+}
+
+// popLocalsDeeperThan discards, at runtime only, the locals a jump out of
+// the current scopes leaves behind. The compiler keeps tracking them.
+func (g *CodeGenerator) popLocalsDeeperThan(offset ast.Offset, depth int) {
+	for i := len(g.locals) - 1; i >= 0 && g.locals[i].depth > depth; i-- {
+		g.emitDiscardLocal(offset, g.locals[i])
+	}
+}
+
+func (g *CodeGenerator) declareLocal(name *scanner.Token) error {
+	for i := len(g.locals) - 1; i >= 0; i-- {
+		if g.locals[i].depth < g.scopeDepth {
+			break
+		}
+
+		if g.locals[i].name == name.Lexeme {
+			return newCompileError(diagnostic.CodeRedeclared, "Variable with this name already declared in this scope: "+name.Lexeme, ast.Offset(name.Offset))
+		}
+	}
+
+	g.locals = append(g.locals, local{name: name.Lexeme, depth: g.scopeDepth})
+
+	return nil
+}
+
+func (g *CodeGenerator) markInitialized() {
+	g.locals[len(g.locals)-1].initialized = true
+}
+
+// resolveLocal returns the stack slot of name, or -1 if it is not a local
+// of this function.
+func (fs *funcState) resolveLocal(name *scanner.Token) (int64, error) {
+	for i := len(fs.locals) - 1; i >= 0; i-- {
+		if fs.locals[i].name != name.Lexeme {
+			continue
+		}
+
+		if !fs.locals[i].initialized {
+			return -1, newCompileError(diagnostic.CodeOwnInitializer, "Cannot read local variable in its own initializer: "+name.Lexeme, ast.Offset(name.Offset))
+		}
+
+		return int64(i), nil
+	}
+
+	return -1, nil
+}
+
+// resolveUpvalue returns the upvalue index of name, captured from the
+// enclosing functions, or -1 if it is a global.
+func (fs *funcState) resolveUpvalue(name *scanner.Token) (int64, error) {
+	if fs.enclosing == nil {
+		return -1, nil
+	}
+
+	slot, err := fs.enclosing.resolveLocal(name)
+	if err != nil {
+		return -1, err
+	}
+
+	if slot >= 0 {
+		fs.enclosing.locals[slot].isCaptured = true
+
+		return fs.addUpvalue(true, slot), nil
+	}
+
+	index, err := fs.enclosing.resolveUpvalue(name)
+	if err != nil || index < 0 {
+		return -1, err
+	}
+
+	return fs.addUpvalue(false, index), nil
+}
+
+func (fs *funcState) addUpvalue(isLocal bool, index int64) int64 {
+	info := bytecode.UpvalueInfo{IsLocal: isLocal, Index: int(index)}
+
+	for i, upvalue := range fs.function.Upvalues {
+		if upvalue == info {
+			return int64(i)
+		}
+	}
+
+	fs.function.Upvalues = append(fs.function.Upvalues, info)
+
+	return int64(len(fs.function.Upvalues) - 1)
+}
+
+// namedVariable emits the get or set instruction for name, looking it up as
+// a local, then an upvalue and finally a global.
+func (g *CodeGenerator) namedVariable(offset ast.Offset, name *scanner.Token, set bool) error {
+	getOp, setOp := bytecode.OP_GET_GLOBAL, bytecode.OP_SET_GLOBAL
+
+	arg, err := g.resolveLocal(name)
+	if err != nil {
+		return err
+	}
+
+	if arg >= 0 {
+		getOp, setOp = bytecode.OP_GET_LOCAL, bytecode.OP_SET_LOCAL
+	} else {
+		arg, err = g.resolveUpvalue(name)
+		if err != nil {
+			return err
+		}
+
+		if arg >= 0 {
+			getOp, setOp = bytecode.OP_GET_UPVALUE, bytecode.OP_SET_UPVALUE
+		} else {
+			arg = g.makeConstant(name.Lexeme)
+		}
+	}
+
+	if set {
+		g.emit(offset, setOp, arg)
+	} else {
+		g.emit(offset, getOp, arg)
+	}
+
+	return nil
+}
+
+// emitReturn emits the implicit return at the end of a function body, which
+// is also what a bare `return;` compiles to.
+func (g *CodeGenerator) emitReturn() {
+	g.emitReturnAt(ast.Offset{Line: -1, Index: -1})
+}
+
+func (g *CodeGenerator) emitReturnAt(offset ast.Offset) {
+	if g.fnType == typeInitializer {
+		// an initializer always returns the instance
+		g.emit(offset, bytecode.OP_GET_LOCAL, 0)
+	} else {
+		g.emit(offset, bytecode.OP_NIL)
+	}
+
+	g.emit(offset, bytecode.OP_RETURN)
+}
+
+func (g *CodeGenerator) emitJump(offset ast.Offset, op bytecode.OpCode) int {
+	return g.em.EmitJump(bytecode.Offset(offset), op)
+}
+
+func (g *CodeGenerator) patchJump(at int) {
+	g.em.PatchJump(at)
+}
+
+func (g *CodeGenerator) emitLoop(offset ast.Offset, loopStart int) {
+	g.em.EmitLoop(bytecode.Offset(offset), loopStart)
+}
+
 // ================================================================
 // Expr
 // ================================================================
@@ -107,8 +368,9 @@ func (g *CodeGenerator) VisitAssignExpr(expr *ast.Assign) any {
 		return err
 	}
 
-	constant := g.makeConstant(expr.Name.Lexeme)
-	g.emit(expr.Offset, bytecode.OP_SET_GLOBAL, constant)
+	if err := g.namedVariable(expr.Offset, expr.Name, true); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -144,17 +406,35 @@ func (g *CodeGenerator) VisitBinaryExpr(expr *ast.Binary) any {
 	case scanner.BANG_EQUAL:
 		g.emit(expr.Offset, bytecode.OP_NOT_EQUAL)
 	default:
-		return errors.New("unknown binary operator: " + expr.Operator.Lexeme)
+		return newCompileError(diagnostic.CodeUnsupported, "unknown binary operator: "+expr.Operator.Lexeme, expr.Pos())
 	}
 
 	return nil
 }
 
 func (g *CodeGenerator) VisitCallExpr(expr *ast.Call) any {
+	if err := expr.Callee.Accept(g); err != nil {
+		return err
+	}
+
+	for _, arg := range expr.Arguments {
+		if err := arg.Accept(g); err != nil {
+			return err
+		}
+	}
+
+	g.emit(expr.Offset, bytecode.OP_CALL, int64(len(expr.Arguments)))
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitGetExpr(expr *ast.Get) any {
+	if err := expr.Object.Accept(g); err != nil {
+		return err
+	}
+
+	g.emit(expr.Offset, bytecode.OP_GET_PROPERTY, g.makeConstant(expr.Name.Lexeme))
+
 	return nil
 }
 
@@ -162,6 +442,56 @@ func (g *CodeGenerator) VisitGroupingExpr(expr *ast.Grouping) any {
 	return expr.Expression.Accept(g)
 }
 
+func (g *CodeGenerator) VisitIndexExpr(expr *ast.Index) any {
+	if err := expr.Object.Accept(g); err != nil {
+		return err
+	}
+
+	if err := expr.Index.Accept(g); err != nil {
+		return err
+	}
+
+	g.emit(expr.Offset, bytecode.OP_GET_INDEX)
+
+	return nil
+}
+
+// VisitIndexSetExpr evaluates the value first, like VisitSetExpr.
+func (g *CodeGenerator) VisitIndexSetExpr(expr *ast.IndexSet) any {
+	if err := expr.Value.Accept(g); err != nil {
+		return err
+	}
+
+	if err := expr.Object.Accept(g); err != nil {
+		return err
+	}
+
+	if err := expr.Index.Accept(g); err != nil {
+		return err
+	}
+
+	g.emit(expr.Offset, bytecode.OP_SET_INDEX)
+
+	return nil
+}
+
+// VisitLambdaExpr leaves the closure on the stack as the expression's value.
+func (g *CodeGenerator) VisitLambdaExpr(expr *ast.Lambda) any {
+	return g.genFunction(expr.Function, typeFunction)
+}
+
+func (g *CodeGenerator) VisitListExpr(expr *ast.List) any {
+	for _, element := range expr.Elements {
+		if err := element.Accept(g); err != nil {
+			return err
+		}
+	}
+
+	g.emit(expr.Offset, bytecode.OP_LIST, int64(len(expr.Elements)))
+
+	return nil
+}
+
 func (g *CodeGenerator) VisitLiteralExpr(expr *ast.Literal) any {
 	g.emitConstant(expr.Offset, expr.Value)
 
@@ -169,22 +499,122 @@ func (g *CodeGenerator) VisitLiteralExpr(expr *ast.Literal) any {
 }
 
 func (g *CodeGenerator) VisitLogicalExpr(expr *ast.Logical) any {
+	if err := expr.Left.Accept(g); err != nil {
+		return err
+	}
+
+	var endJump int
+
+	switch expr.Operator.TokenType {
+	case scanner.AND:
+		endJump = g.emitJump(expr.Offset, bytecode.OP_JUMP_IF_FALSE)
+	case scanner.OR:
+		elseJump := g.emitJump(expr.Offset, bytecode.OP_JUMP_IF_FALSE)
+		endJump = g.emitJump(expr.Offset, bytecode.OP_JUMP)
+		g.patchJump(elseJump)
+	default:
+		return newCompileError(diagnostic.CodeUnsupported, "unknown logical operator: "+expr.Operator.Lexeme, expr.Pos())
+	}
+
+	g.emit(expr.Offset, bytecode.OP_POP)
+
+	if err := expr.Right.Accept(g); err != nil {
+		return err
+	}
+
+	g.patchJump(endJump)
+
 	return nil
 }
 
+// VisitMapExpr pushes each key and then its value, in source order.
+func (g *CodeGenerator) VisitMapExpr(expr *ast.Map) any {
+	for i := range expr.Keys {
+		if err := expr.Keys[i].Accept(g); err != nil {
+			return err
+		}
+
+		if err := expr.Values[i].Accept(g); err != nil {
+			return err
+		}
+	}
+
+	g.emit(expr.Offset, bytecode.OP_MAP, int64(len(expr.Keys)))
+
+	return nil
+}
+
+// VisitSetExpr evaluates the value before the object, in the same order as
+// the tree-walking interpreter.
 func (g *CodeGenerator) VisitSetExpr(expr *ast.Set) any {
+	if err := expr.Value.Accept(g); err != nil {
+		return err
+	}
+
+	if err := expr.Object.Accept(g); err != nil {
+		return err
+	}
+
+	g.emit(expr.Offset, bytecode.OP_SET_PROPERTY, g.makeConstant(expr.Name.Lexeme))
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitSuperExpr(expr *ast.Super) any {
+	if len(g.classes) == 0 {
+		return newCompileError(diagnostic.CodeInvalidThisOrSuper, "cannot use 'super' outside of a class", expr.Pos())
+	} else if !g.classes[len(g.classes)-1].hasSuperclass {
+		return newCompileError(diagnostic.CodeInvalidThisOrSuper, "cannot use 'super' in a class with no superclass", expr.Pos())
+	}
+
+	if err := g.namedVariable(expr.Offset, syntheticToken(expr.Keyword, "this"), false); err != nil {
+		return err
+	}
+
+	if err := g.namedVariable(expr.Offset, expr.Keyword, false); err != nil {
+		return err
+	}
+
+	g.emit(expr.Offset, bytecode.OP_GET_SUPER, g.makeConstant(expr.Method.Lexeme))
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitThisExpr(expr *ast.This) any {
+	if len(g.classes) == 0 {
+		return newCompileError(diagnostic.CodeInvalidThisOrSuper, "cannot use 'this' outside of a class", expr.Pos())
+	}
+
+	if err := g.namedVariable(expr.Offset, expr.Keyword, false); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitTernaryExpr(expr *ast.Ternary) any {
+	if err := expr.Left.Accept(g); err != nil {
+		return err
+	}
+
+	elseJump := g.emitJump(expr.Offset, bytecode.OP_JUMP_IF_FALSE)
+	g.emit(expr.Offset, bytecode.OP_POP)
+
+	if err := expr.Mid.Accept(g); err != nil {
+		return err
+	}
+
+	endJump := g.emitJump(expr.Offset, bytecode.OP_JUMP)
+
+	g.patchJump(elseJump)
+	g.emit(expr.Offset, bytecode.OP_POP)
+
+	if err := expr.Right.Accept(g); err != nil {
+		return err
+	}
+
+	g.patchJump(endJump)
+
 	return nil
 }
 
@@ -201,15 +631,16 @@ func (g *CodeGenerator) VisitUnaryExpr(expr *ast.Unary) any {
 		g.emit(expr.Offset, bytecode.OP_NOT)
 
 	default:
-		return errors.New("unknown unary operator: " + expr.Operator.Lexeme)
+		return newCompileError(diagnostic.CodeUnsupported, "unknown unary operator: "+expr.Operator.Lexeme, expr.Pos())
 	}
 
 	return nil
 }
 
 func (g *CodeGenerator) VisitVariableExpr(expr *ast.Variable) any {
-	constant := g.makeConstant(expr.Name.Lexeme)
-	g.emit(expr.Offset, bytecode.OP_GET_GLOBAL, constant)
+	if err := g.namedVariable(expr.Offset, expr.Name, false); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -219,10 +650,86 @@ func (g *CodeGenerator) VisitVariableExpr(expr *ast.Variable) any {
 // ================================================================
 
 func (g *CodeGenerator) VisitBlockStmt(stmt *ast.Block) any {
+	g.beginScope()
+
+	for _, s := range stmt.Statements {
+		if err := g.genStmt(s); err != nil {
+			return err
+		}
+	}
+
+	g.endScope(stmt.Offset)
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitClassStmt(stmt *ast.Class) any {
+	if g.scopeDepth > 0 {
+		if err := g.declareLocal(stmt.Name); err != nil {
+			return err
+		}
+	}
+
+	g.emit(stmt.Offset, bytecode.OP_CLASS, g.makeConstant(stmt.Name.Lexeme))
+
+	if g.scopeDepth > 0 {
+		g.markInitialized()
+	} else {
+		g.emit(stmt.Offset, bytecode.OP_DEFINE_GLOBAL, g.makeConstant(stmt.Name.Lexeme))
+	}
+
+	class := &classState{hasSuperclass: stmt.Superclass != nil}
+
+	g.classes = append(g.classes, class)
+	defer func() { g.classes = g.classes[:len(g.classes)-1] }()
+
+	if stmt.Superclass != nil {
+		if stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
+			return newCompileError(diagnostic.CodeSelfInheritance, "a class cannot inherit from itself", stmt.Superclass.Pos())
+		}
+
+		if err := stmt.Superclass.Accept(g); err != nil {
+			return err
+		}
+
+		// methods reach the superclass through a "super" local/upvalue
+		g.beginScope()
+		if err := g.declareLocal(syntheticToken(stmt.Name, "super")); err != nil {
+			return err
+		}
+		g.markInitialized()
+
+		if err := g.namedVariable(stmt.Offset, stmt.Name, false); err != nil {
+			return err
+		}
+
+		g.emit(stmt.Offset, bytecode.OP_INHERIT)
+	}
+
+	if err := g.namedVariable(stmt.Offset, stmt.Name, false); err != nil {
+		return err
+	}
+
+	for _, method := range stmt.Methods {
+		fnType := typeMethod
+
+		if method.Name.Lexeme == "init" {
+			fnType = typeInitializer
+		}
+
+		if err := g.genFunction(method, fnType); err != nil {
+			return err
+		}
+
+		g.emit(method.Offset, bytecode.OP_METHOD, g.makeConstant(method.Name.Lexeme))
+	}
+
+	g.emit(stmt.Offset, bytecode.OP_POP)
+
+	if stmt.Superclass != nil {
+		g.endScope(stmt.Offset)
+	}
+
 	return nil
 }
 
@@ -237,10 +744,96 @@ func (g *CodeGenerator) VisitExpressionStmt(stmt *ast.Expression) any {
 }
 
 func (g *CodeGenerator) VisitFunctionStmt(stmt *ast.Function) any {
+	if g.scopeDepth > 0 {
+		if err := g.declareLocal(stmt.Name); err != nil {
+			return err
+		}
+
+		// a local function may refer to itself
+		g.markInitialized()
+	}
+
+	if err := g.genFunction(stmt, typeFunction); err != nil {
+		return err
+	}
+
+	if g.scopeDepth > 0 {
+		return nil
+	}
+
+	constant := g.makeConstant(stmt.Name.Lexeme)
+	g.emit(stmt.Offset, bytecode.OP_DEFINE_GLOBAL, constant)
+
+	return nil
+}
+
+// genFunction compiles a function body into its own chunk and emits the
+// instruction that loads the resulting function onto the stack.
+func (g *CodeGenerator) genFunction(stmt *ast.Function, fnType functionType) error {
+	function := bytecode.NewFunction(stmt.Name.Lexeme, len(stmt.Params))
+
+	g.funcState = newFuncState(g.funcState, function, fnType, NewChunkEmitter(function.Chunk))
+	g.beginScope()
+
+	err := g.functionBody(stmt)
+
+	g.funcState = g.enclosing
+
+	if err != nil {
+		return err
+	}
+
+	g.emit(stmt.Offset, bytecode.OP_CLOSURE, g.makeConstant(function))
+
+	return nil
+}
+
+func (g *CodeGenerator) functionBody(stmt *ast.Function) error {
+	for _, param := range stmt.Params {
+		if err := g.declareLocal(param); err != nil {
+			return err
+		}
+
+		g.markInitialized()
+	}
+
+	for _, s := range stmt.Body {
+		if err := g.genStmt(s); err != nil {
+			return err
+		}
+	}
+
+	// the frame is discarded as a whole on return, so no endScope pops
+	g.emitReturn()
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitIfStmt(stmt *ast.If) any {
+	if err := stmt.Condition.Accept(g); err != nil {
+		return err
+	}
+
+	thenJump := g.emitJump(stmt.Offset, bytecode.OP_JUMP_IF_FALSE)
+	g.emit(stmt.Offset, bytecode.OP_POP)
+
+	if err := g.genStmt(stmt.ThenBranch); err != nil {
+		return err
+	}
+
+	elseJump := g.emitJump(stmt.Offset, bytecode.OP_JUMP)
+
+	g.patchJump(thenJump)
+	g.emit(stmt.Offset, bytecode.OP_POP)
+
+	if stmt.ElseBranch != nil {
+		if err := g.genStmt(stmt.ElseBranch); err != nil {
+			return err
+		}
+	}
+
+	g.patchJump(elseJump)
+
 	return nil
 }
 
@@ -255,12 +848,49 @@ func (g *CodeGenerator) VisitPrintStmt(stmt *ast.Print) any {
 }
 
 func (g *CodeGenerator) VisitReturnStmt(stmt *ast.Return) any {
+	if g.fnType == typeScript {
+		return newCompileError(diagnostic.CodeInvalidReturn, "cannot return from top-level code", stmt.Pos())
+	}
+
+	if stmt.Value == nil {
+		if err := g.exitTries(0); err != nil {
+			return err
+		}
+
+		g.emitReturnAt(stmt.Offset)
+
+		return nil
+	}
+
+	if g.fnType == typeInitializer {
+		return newCompileError(diagnostic.CodeInvalidReturn, "cannot return a value from an initializer", stmt.Value.Pos())
+	}
+
+	if err := stmt.Value.Accept(g); err != nil {
+		return err
+	}
+
+	// the return value waits in a hidden slot while finally blocks run
+	g.locals = append(g.locals, local{depth: g.scopeDepth, initialized: true})
+	err := g.exitTries(0)
+	g.locals = g.locals[:len(g.locals)-1]
+
+	if err != nil {
+		return err
+	}
+
 	g.emit(stmt.Offset, bytecode.OP_RETURN)
 
 	return nil
 }
 
 func (g *CodeGenerator) VisitVarStmt(stmt *ast.Var) any {
+	if g.scopeDepth > 0 {
+		if err := g.declareLocal(stmt.Name); err != nil {
+			return err
+		}
+	}
+
 	if stmt.Initializer == nil {
 		g.emitConstant(stmt.Offset, nil)
 	} else {
@@ -269,6 +899,13 @@ func (g *CodeGenerator) VisitVarStmt(stmt *ast.Var) any {
 		}
 	}
 
+	// a local's value simply stays in its stack slot
+	if g.scopeDepth > 0 {
+		g.markInitialized()
+
+		return nil
+	}
+
 	constant := g.makeConstant(stmt.Name.Lexeme)
 	g.emit(stmt.Offset, bytecode.OP_DEFINE_GLOBAL, constant)
 
@@ -276,13 +913,336 @@ func (g *CodeGenerator) VisitVarStmt(stmt *ast.Var) any {
 }
 
 func (g *CodeGenerator) VisitWhileStmt(stmt *ast.While) any {
+	l := &loop{start: g.em.Size(), scopeDepth: g.scopeDepth, tries: len(g.tries)}
+
+	g.loops = append(g.loops, l)
+	defer func() { g.loops = g.loops[:len(g.loops)-1] }()
+
+	if err := stmt.Condition.Accept(g); err != nil {
+		return err
+	}
+
+	exitJump := g.emitJump(stmt.Offset, bytecode.OP_JUMP_IF_FALSE)
+	g.emit(stmt.Offset, bytecode.OP_POP)
+
+	if err := g.genStmt(stmt.Body); err != nil {
+		return err
+	}
+
+	for _, at := range l.continueJumps {
+		g.patchJump(at)
+	}
+
+	if stmt.Increment != nil {
+		if err := stmt.Increment.Accept(g); err != nil {
+			return err
+		}
+
+		g.emit(stmt.Offset, bytecode.OP_POP)
+	}
+
+	g.emitLoop(stmt.Offset, l.start)
+
+	g.patchJump(exitJump)
+	g.emit(stmt.Offset, bytecode.OP_POP)
+
+	// break leaves after the condition has already been popped
+	for _, at := range l.breakJumps {
+		g.patchJump(at)
+	}
+
+	return nil
+}
+
+// VisitImportStmt binds the module, or each listed member of it, like a
+// variable declaration. Every member reimports the module, which the VM
+// finds in its cache.
+func (g *CodeGenerator) VisitImportStmt(stmt *ast.Import) any {
+	path := g.makeConstant(stmt.Path.Literal.(string))
+
+	if stmt.Alias != nil {
+		return g.bindImport(stmt.Alias, func() {
+			g.emit(stmt.Offset, bytecode.OP_IMPORT, path)
+		})
+	}
+
+	for _, name := range stmt.Names {
+		err := g.bindImport(name, func() {
+			g.emit(stmt.Offset, bytecode.OP_IMPORT, path)
+			g.emit(ast.Offset(name.Offset), bytecode.OP_GET_PROPERTY, g.makeConstant(name.Lexeme))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bindImport declares name and initializes it with the value value emits.
+func (g *CodeGenerator) bindImport(name *scanner.Token, value func()) error {
+	if g.scopeDepth > 0 {
+		if err := g.declareLocal(name); err != nil {
+			return err
+		}
+
+		value()
+		g.markInitialized()
+
+		return nil
+	}
+
+	value()
+	g.emit(ast.Offset(name.Offset), bytecode.OP_DEFINE_GLOBAL, g.makeConstant(name.Lexeme))
+
+	return nil
+}
+
+// VisitForInStmt keeps the iterator in a hidden local and binds the loop
+// variable in a scope of its own on each pass:
+//
+//	iterable; OP_ITER
+//	start: OP_FOR_ITER -> exit; body; OP_LOOP -> start
+//	exit:  pop the iterator
+func (g *CodeGenerator) VisitForInStmt(stmt *ast.ForIn) any {
+	g.beginScope()
+
+	if err := stmt.Iterable.Accept(g); err != nil {
+		return err
+	}
+
+	g.emit(stmt.Iterable.Pos(), bytecode.OP_ITER)
+	g.locals = append(g.locals, local{depth: g.scopeDepth, initialized: true})
+
+	l := &loop{start: g.em.Size(), scopeDepth: g.scopeDepth, tries: len(g.tries)}
+
+	g.loops = append(g.loops, l)
+	defer func() { g.loops = g.loops[:len(g.loops)-1] }()
+
+	exitJump := g.emitJump(stmt.Iterable.Pos(), bytecode.OP_FOR_ITER)
+
+	g.beginScope()
+
+	if err := g.declareLocal(stmt.Name); err != nil {
+		return err
+	}
+	g.markInitialized()
+
+	if err := g.genStmt(stmt.Body); err != nil {
+		return err
+	}
+
+	g.endScope(stmt.Offset)
+
+	for _, at := range l.continueJumps {
+		g.patchJump(at)
+	}
+
+	g.emitLoop(stmt.Offset, l.start)
+
+	g.patchJump(exitJump)
+
+	for _, at := range l.breakJumps {
+		g.patchJump(at)
+	}
+
+	g.endScope(stmt.Offset)
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitBreakStmt(stmt *ast.Break) any {
+	if len(g.loops) == 0 {
+		return newCompileError(diagnostic.CodeOutsideLoop, "break statement not within a loop", stmt.Pos())
+	}
+
+	l := g.loops[len(g.loops)-1]
+	if err := g.exitTries(l.tries); err != nil {
+		return err
+	}
+
+	g.popLocalsDeeperThan(stmt.Offset, l.scopeDepth)
+	l.breakJumps = append(l.breakJumps, g.emitJump(stmt.Offset, bytecode.OP_JUMP))
+
 	return nil
 }
 
 func (g *CodeGenerator) VisitContinueStmt(stmt *ast.Continue) any {
+	if len(g.loops) == 0 {
+		return newCompileError(diagnostic.CodeOutsideLoop, "continue statement not within a loop", stmt.Pos())
+	}
+
+	// continue runs the increment (emitted after the body) before looping
+	l := g.loops[len(g.loops)-1]
+	if err := g.exitTries(l.tries); err != nil {
+		return err
+	}
+
+	g.popLocalsDeeperThan(stmt.Offset, l.scopeDepth)
+	l.continueJumps = append(l.continueJumps, g.emitJump(stmt.Offset, bytecode.OP_JUMP))
+
 	return nil
 }
+
+func (g *CodeGenerator) VisitThrowStmt(stmt *ast.Throw) any {
+	if err := stmt.Value.Accept(g); err != nil {
+		return err
+	}
+
+	g.emit(stmt.Offset, bytecode.OP_THROW)
+
+	return nil
+}
+
+// VisitTryStmt emits the body followed by the catch and finally handlers:
+//
+//	body; finally; jump end
+//	catch:   (thrown value is the catch variable) catch body; finally; jump end
+//	finally: (pending error in a hidden slot) finally; OP_RETHROW
+//	end:
+//
+// The catch handler protects the body, the finally handler both the body
+// and the catch block. Each path out of the statement gets its own copy of
+// the finally block.
+func (g *CodeGenerator) VisitTryStmt(stmt *ast.Try) any {
+	depth := len(g.locals)
+	t := &tryState{finally: stmt.Finally}
+
+	if err := g.protect(t, stmt.Body); err != nil {
+		return err
+	}
+
+	bodyRanges := t.ranges
+	t.ranges = nil
+
+	if err := g.genFinally(stmt); err != nil {
+		return err
+	}
+
+	exits := []int{g.emitJump(stmt.Offset, bytecode.OP_JUMP)}
+
+	if stmt.CatchBody != nil {
+		g.addHandlers(bodyRanges, depth, false)
+
+		g.beginScope()
+		if err := g.declareLocal(stmt.CatchName); err != nil {
+			return err
+		}
+		g.markInitialized()
+
+		if stmt.Finally != nil {
+			if err := g.protect(t, stmt.CatchBody); err != nil {
+				return err
+			}
+		} else if err := g.genStmt(stmt.CatchBody); err != nil {
+			return err
+		}
+
+		g.endScope(stmt.CatchBody.Offset)
+
+		if err := g.genFinally(stmt); err != nil {
+			return err
+		}
+
+		exits = append(exits, g.emitJump(stmt.Offset, bytecode.OP_JUMP))
+	}
+
+	if stmt.Finally != nil {
+		g.addHandlers(append(bodyRanges, t.ranges...), depth, true)
+
+		g.beginScope()
+		g.locals = append(g.locals, local{depth: g.scopeDepth, initialized: true})
+
+		if err := g.genStmt(stmt.Finally); err != nil {
+			return err
+		}
+
+		// OP_RETHROW consumes the hidden slot, so nothing is popped
+		g.locals = g.locals[:len(g.locals)-1]
+		g.scopeDepth--
+
+		g.emit(stmt.Offset, bytecode.OP_RETHROW)
+	}
+
+	for _, at := range exits {
+		g.patchJump(at)
+	}
+
+	return nil
+}
+
+// protect generates block as code guarded by t.
+func (g *CodeGenerator) protect(t *tryState, block *ast.Block) error {
+	g.tries = append(g.tries, t)
+	defer func() { g.tries = g.tries[:len(g.tries)-1] }()
+
+	t.open(g.em.Size())
+	err := g.genStmt(block)
+	t.close(g.em.Size())
+
+	return err
+}
+
+// addHandlers points every protected range at the handler emitted next.
+func (g *CodeGenerator) addHandlers(ranges [][2]int, depth int, finally bool) {
+	target := g.em.Size()
+
+	for _, r := range ranges {
+		g.em.AddHandler(bytecode.Handler{Start: r[0], End: r[1], Target: target, Depth: depth, Finally: finally})
+	}
+}
+
+func (g *CodeGenerator) genFinally(stmt *ast.Try) error {
+	if stmt.Finally == nil {
+		return nil
+	}
+
+	return g.genStmt(stmt.Finally)
+}
+
+// exitTries emits, innermost first, the finally blocks of the try
+// statements a jump leaves when it goes out to the code around tries[from].
+// While a finally block runs the statements it belongs to, and the ones
+// inside them, stop protecting the code.
+func (g *CodeGenerator) exitTries(from int) error {
+	tries := g.tries
+	defer func() { g.tries = tries }()
+
+	hasFinally := false
+	for _, t := range tries[from:] {
+		hasFinally = hasFinally || t.finally != nil
+	}
+
+	if !hasFinally {
+		return nil
+	}
+
+	for k := len(tries) - 1; k >= from; k-- {
+		tries[k].close(g.em.Size())
+
+		if tries[k].finally == nil {
+			continue
+		}
+
+		g.tries = tries[:k]
+		if err := g.genStmt(tries[k].finally); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tries[from:] {
+		t.open(g.em.Size())
+	}
+
+	return nil
+}
+
+// syntheticToken makes an identifier token for a name the source never
+// spells out, such as the implicit "this" of a super expression.
+func syntheticToken(at *scanner.Token, lexeme string) *scanner.Token {
+	return &scanner.Token{
+		TokenType: scanner.IDENTIFIER,
+		Lexeme:    lexeme,
+		Offset:    at.Offset,
+	}
+}
@@ -0,0 +1,45 @@
+package diagnostic
+
+// Code identifies a kind of error. The leading digit after "E" is the phase
+// that reports it.
+type Code string
+
+// Scanner
+const (
+	CodeUnexpectedCharacter Code = "E0001"
+	CodeUnterminatedString  Code = "E0002"
+	CodeUnterminatedComment Code = "E0003"
+	CodeInvalidEscape       Code = "E0004"
+	CodeInvalidNumber       Code = "E0005"
+)
+
+// Parser
+const (
+	CodeSyntax                  Code = "E0100"
+	CodeInvalidAssignmentTarget Code = "E0101"
+	CodeTooManyArguments        Code = "E0102"
+	CodeOutsideLoop             Code = "E0103"
+	CodeSelfInheritance         Code = "E0104"
+)
+
+// Resolver and code generator
+const (
+	CodeRedeclared         Code = "E0200"
+	CodeOwnInitializer     Code = "E0201"
+	CodeInvalidReturn      Code = "E0202"
+	CodeInvalidThisOrSuper Code = "E0203"
+	CodeUnsupported        Code = "E0209"
+)
+
+// Runtime
+const (
+	CodeRuntime       Code = "E0300"
+	CodeType          Code = "E0301"
+	CodeUndefined     Code = "E0302"
+	CodeArity         Code = "E0303"
+	CodeStackOverflow Code = "E0304"
+	CodeUncaught      Code = "E0305" // a value thrown by the program was not caught
+	CodeIndex         Code = "E0306"
+	CodeImport        Code = "E0307" // a module could not be found or loaded
+	CodePermission    Code = "E0308" // a file outside the allowed directories
+)
@@ -0,0 +1,55 @@
+package diagnostic
+
+// Span is a range of source text. Line and Column are 1-based and count
+// runes; Index is the rune index of the first character and Length the
+// number of runes covered. Line < 1 means the position is unknown.
+//
+// scanner.Offset, ast.Offset and bytecode.Offset share this layout, so any
+// of them converts to a Span directly.
+type Span struct {
+	Line   int
+	Column int
+	Index  int
+	Length int
+}
+
+func (s Span) Known() bool {
+	return s.Line >= 1
+}
+
+// Frame is one entry of a HOLang stack trace: a function and the position
+// it had reached. File is the imported module the function belongs to, or
+// empty for the script being run.
+type Frame struct {
+	Function string
+	File     string
+	Span     Span
+}
+
+// Diagnostic is everything needed to report one error.
+type Diagnostic struct {
+	Code    Code
+	Message string
+	File    string // the module Span is in; empty for the script being run
+	Span    Span
+	Trace   []Frame // innermost first; only runtime errors have one
+}
+
+// Traced builds the diagnostic of a runtime error, which points at the
+// position reached in the innermost frame.
+func Traced(code Code, message string, trace []Frame) Diagnostic {
+	d := Diagnostic{Code: code, Message: message, Trace: trace}
+	if len(trace) > 0 {
+		d.File = trace[0].File
+		d.Span = trace[0].Span
+	}
+
+	return d
+}
+
+// Reportable is implemented by the errors of every phase: scanner, parser,
+// resolver, code generator and both engines' runtime errors.
+type Reportable interface {
+	error
+	Diagnostic() Diagnostic
+}
@@ -0,0 +1,3 @@
+module internal/diagnostic
+
+go 1.24.0
@@ -0,0 +1,105 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Source is the script diagnostics are reported against.
+type Source struct {
+	Name  string
+	lines []string
+}
+
+// NewSource keeps text for quoting offending lines. text may be empty, for
+// example when running compiled bytecode, and then only positions are shown.
+func NewSource(name, text string) *Source {
+	s := &Source{Name: name}
+
+	if text != "" {
+		s.lines = strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	}
+
+	return s
+}
+
+// Render writes d in the style of rustc and Elm:
+//
+//	error[E0302]: undefined variable: x
+//	 --> game.holang:3:10
+//	  |
+//	3 |   return x + 1;
+//	  |          ^
+//	  = at inner (game.holang:3:10)
+//	  = at <script> (game.holang:7:1)
+func (s *Source) Render(w io.Writer, d Diagnostic) {
+	fmt.Fprintf(w, "error[%s]: %s\n", d.Code, d.Message)
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(max(d.Span.Line, 0))))
+
+	fmt.Fprintf(w, "%s--> %s\n", gutter, s.location(d.File, d.Span))
+
+	// a diagnostic in another file is shown without quoting s
+	if line, ok := s.line(d.Span.Line); ok && (d.File == "" || d.File == s.Name) {
+		fmt.Fprintf(w, "%s |\n", gutter)
+		fmt.Fprintf(w, "%d | %s\n", d.Span.Line, line)
+		fmt.Fprintf(w, "%s | %s\n", gutter, underline(line, d.Span))
+	}
+
+	for _, frame := range d.Trace {
+		fmt.Fprintf(w, "%s = at %s (%s)\n", gutter, frame.Function, s.location(frame.File, frame.Span))
+	}
+}
+
+// location names a position in file, or in s if file is empty.
+func (s *Source) location(file string, span Span) string {
+	if file == "" {
+		file = s.Name
+	}
+
+	if !span.Known() {
+		return file
+	}
+
+	return fmt.Sprintf("%s:%d:%d", file, span.Line, span.Column)
+}
+
+func (s *Source) line(number int) (string, bool) {
+	if number < 1 || number > len(s.lines) {
+		return "", false
+	}
+
+	return s.lines[number-1], true
+}
+
+// underline returns the "^~~~" marker for span, indented to its column. The
+// indent copies tabs from line so the marker lines up however tabs render,
+// and both the indent and the marker count terminal cells, so wide
+// characters such as Hangul take two; a span running past the end of line is
+// cut there.
+func underline(line string, span Span) string {
+	runes := []rune(line)
+	start := min(max(span.Column-1, 0), len(runes))
+
+	var b strings.Builder
+	for _, r := range runes[:start] {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+
+	end := min(start+max(span.Length, 1), len(runes))
+
+	width := 0
+	for _, r := range runes[start:end] {
+		width += runeWidth(r)
+	}
+
+	b.WriteString("^" + strings.Repeat("~", max(width, 1)-1))
+
+	return b.String()
+}
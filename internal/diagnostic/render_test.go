@@ -0,0 +1,54 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSource_RenderUnderlinesSpanAndTrace(t *testing.T) {
+	source := NewSource("game.holang", "var a = 1;\n\tprint a + nothing;\n")
+
+	var out bytes.Buffer
+	source.Render(&out, Diagnostic{
+		Code:    CodeUndefined,
+		Message: "undefined variable: nothing",
+		Span:    Span{Line: 2, Column: 12, Index: 22, Length: 7},
+		Trace:   []Frame{{Function: "<script>", Span: Span{Line: 2, Column: 12}}},
+	})
+
+	want := "error[E0302]: undefined variable: nothing\n" +
+		" --> game.holang:2:12\n" +
+		"  |\n" +
+		"2 | \tprint a + nothing;\n" +
+		"  | \t          ^~~~~~~\n" +
+		"  = at <script> (game.holang:2:12)\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSource_RenderWithoutText(t *testing.T) {
+	var out bytes.Buffer
+	NewSource("game.hoc", "").Render(&out, Diagnostic{Code: CodeRuntime, Message: "boom", Span: Span{Line: 12, Column: 3}})
+
+	want := "error[E0300]: boom\n  --> game.hoc:12:3\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSource_RenderUnderlinesWideCharactersByCells(t *testing.T) {
+	source := NewSource("game.holang", "print 이름 + nil;\n")
+
+	var out bytes.Buffer
+	source.Render(&out, Diagnostic{Code: CodeType, Message: "operands must be numbers", Span: Span{Line: 1, Column: 7, Index: 6, Length: 8}})
+
+	want := "error[E0301]: operands must be numbers\n" +
+		" --> game.holang:1:7\n" +
+		"  |\n" +
+		"1 | print 이름 + nil;\n" +
+		"  |       ^~~~~~~~~~\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package diagnostic
+
+import "unicode"
+
+// wideRanges are the East Asian Wide and Fullwidth blocks, which terminals
+// draw two cells wide: Hangul, kana, CJK ideographs, fullwidth forms and
+// emoji.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals, symbols and punctuation
+	{0x3041, 0x33FF},   // kana, Bopomofo, Hangul compatibility Jamo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK Extension A
+	{0x4E00, 0x9FFF},   // CJK Unified Ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo Extended-A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK Compatibility Ideographs
+	{0xFE10, 0xFE19},   // vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility and small forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x1F300, 0x1F64F}, // pictographs and emoticons
+	{0x1F900, 0x1F9FF}, // supplemental pictographs
+	{0x20000, 0x2FFFD}, // CJK Extensions B and on
+	{0x30000, 0x3FFFD},
+}
+
+// runeWidth is the number of terminal cells r takes: two for wide
+// characters, none for combining marks and Hangul vowel and final Jamo,
+// which join the character before them, and one otherwise.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me) || (r >= 0x1160 && r <= 0x11FF) || r == 0x200B {
+		return 0
+	}
+
+	for _, wide := range wideRanges {
+		if r < wide.lo {
+			break
+		}
+
+		if r <= wide.hi {
+			return 2
+		}
+	}
+
+	return 1
+}
@@ -1,5 +1,7 @@
 package interpreter
 
+import "internal/diagnostic"
+
 type Environment struct {
 	enclosing *Environment
 
@@ -35,7 +37,7 @@ func (e *Environment) Assign(name string, value any) error {
 	env := e.findDefinition(name)
 
 	if env == nil {
-		return NewRuntimeErrorWithLog("cannot assign to undefined variable: " + name)
+		return NewRuntimeError(diagnostic.CodeUndefined, "cannot assign to undefined variable: "+name)
 	}
 
 	env.Values[name] = value
@@ -47,7 +49,7 @@ func (e *Environment) Get(name string) (any, error) {
 	env := e.findDefinition(name)
 
 	if env == nil {
-		return nil, NewRuntimeErrorWithLog("undefined variable: " + name)
+		return nil, NewRuntimeError(diagnostic.CodeUndefined, "undefined variable: "+name)
 	}
 
 	return env.Values[name], nil
@@ -72,7 +74,7 @@ func (e *Environment) AssignAt(distance int, name string, value any) error {
 
 	_, ok := env.Values[name]
 	if !ok {
-		return NewRuntimeErrorWithLog("cannot assign to undefined variable: " + name)
+		return NewRuntimeError(diagnostic.CodeUndefined, "cannot assign to undefined variable: "+name)
 	}
 
 	env.Values[name] = value
@@ -1,25 +1,160 @@
 package interpreter
 
-import "internal/util/log"
+import (
+	"fmt"
+	"internal/ast"
+	"internal/diagnostic"
+)
 
+// RuntimeError is a failure while running a program, or a value thrown by
+// a throw statement. As it propagates out of the evaluator it picks up the
+// position of the innermost node that failed, and one stack frame for every
+// function it leaves, until a try statement catches it.
 type RuntimeError struct {
 	Message string
+	Code    diagnostic.Code
+	Trace   []diagnostic.Frame
+
+	offset  ast.Offset // position reached in the function being left
+	located bool
+
+	thrown bool // raised by a throw statement; value is what was thrown
+	value  any
+
+	cause error // the loader error a failed import wraps
 }
 
-func NewRuntimeErrorWithLog(message string) *RuntimeError {
-	err := &RuntimeError{
+// NewRuntimeError makes an error that a try statement may still catch; it is
+// logged only if it escapes the program.
+func NewRuntimeError(code diagnostic.Code, message string) *RuntimeError {
+	return &RuntimeError{
 		Message: message,
+		Code:    code,
+	}
+}
+
+// newThrow wraps a value thrown by the program so it unwinds like a runtime
+// error.
+func newThrow(value any) *RuntimeError {
+	return &RuntimeError{
+		Message: "uncaught exception: " + describeThrown(value),
+		Code:    diagnostic.CodeUncaught,
+		thrown:  true,
+		value:   value,
 	}
+}
 
-	log.Error("Runtime error", log.E(err))
+// errorClass is the class of the objects a catch clause receives for
+// runtime errors.
+var errorClass = &Class{name: "Error", methods: map[string]*Function{}}
 
-	return err
+// caughtValue is what a catch clause binds: the thrown value, or an Error
+// object with message and line fields for a runtime error.
+func (e *RuntimeError) caughtValue() any {
+	if e.thrown {
+		return e.value
+	}
+
+	return &Instance{
+		class: errorClass,
+		fields: map[string]any{
+			"message": e.Message,
+			"line":    int64(e.span().Line),
+		},
+	}
+}
+
+// span is where the error happened: the innermost frame once the error has
+// left a function, otherwise the node that located it.
+func (e *RuntimeError) span() diagnostic.Span {
+	if len(e.Trace) > 0 {
+		return e.Trace[0].Span
+	}
+
+	return diagnostic.Span(e.offset)
+}
+
+// describeThrown shows an Error object by its message and anything else as
+// print would.
+func describeThrown(value any) string {
+	if instance, ok := value.(*Instance); ok && instance.class == errorClass {
+		return fmt.Sprint(instance.fields["message"])
+	}
+
+	return fmt.Sprint(value)
 }
 
 func (e *RuntimeError) Error() string {
 	return e.Message
 }
 
+func (e *RuntimeError) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Traced(e.Code, e.Message, e.Trace)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.cause
+}
+
+// locate records offset as the error position unless a node nested deeper
+// already did.
+func (e *RuntimeError) locate(offset ast.Offset) {
+	if !e.located {
+		e.offset = offset
+		e.located = true
+	}
+}
+
+// leaveFunction closes the frame of the function the error is unwinding
+// out of; the caller's call expression then locates the next frame. file is
+// the module the function belongs to.
+func (e *RuntimeError) leaveFunction(name string, file string) {
+	frame := diagnostic.Frame{Function: name, File: file}
+	if e.located {
+		frame.Span = diagnostic.Span(e.offset)
+	}
+
+	e.Trace = append(e.Trace, frame)
+	e.located = false
+}
+
+// locateError attaches offset to err if it is a runtime error.
+func locateError(err error, offset ast.Offset) error {
+	if rtErr, ok := err.(*RuntimeError); ok {
+		rtErr.locate(offset)
+	}
+
+	return err
+}
+
+// ResolveError is a static error found by the resolver before the program
+// runs.
+type ResolveError struct {
+	Message string
+	Code    diagnostic.Code
+	Offset  ast.Offset
+}
+
+func newResolveError(code diagnostic.Code, message string, offset ast.Offset) *ResolveError {
+	return &ResolveError{
+		Message: message,
+		Code:    code,
+		Offset:  offset,
+	}
+}
+
+func (e *ResolveError) Error() string {
+	return e.Message
+}
+
+func (e *ResolveError) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{
+		Code:    e.Code,
+		Message: e.Message,
+		Span:    diagnostic.Span(e.Offset),
+	}
+}
+
 type breakSignal struct{}
 
 func (e *breakSignal) Error() string {
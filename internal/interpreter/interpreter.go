@@ -1,10 +1,16 @@
 package interpreter
 
 import (
+	"bufio"
 	"fmt"
 	"internal/ast"
+	"internal/builtin"
+	"internal/diagnostic"
 	"internal/scanner"
 	"internal/util"
+	"internal/util/log"
+	"io"
+	"os"
 )
 
 type valueAndError struct {
@@ -13,39 +19,97 @@ type valueAndError struct {
 }
 
 type Interpreter struct {
-	env     *Environment
-	globals *Environment
-	locals  map[ast.Expr]int
+	env    *Environment
+	module *module // the module whose code is running
+	locals map[ast.Expr]int
+	depth  int // calls in progress, counted as the VM counts call frames
+
+	loader  Loader
+	imports *builtin.Imports
+
+	stdout io.Writer
+	stdin  *bufio.Reader
 }
 
 func NewInterpreter() *Interpreter {
-	globals := NewEnvironment(nil)
-
-	globals.Define("print", &BuiltInFnPrint{})
-	globals.Define("input", &BuiltInFnInput{})
-	globals.Define("clock", &BuiltInFnClock{})
-	globals.Define("str", &BuiltInFnToString{})
-	globals.Define("int", &BuiltInFnToInt{})
-	globals.Define("float", &BuiltInFnToFloat{})
-	globals.Define("rand", &BuiltInFnRand{})
-	globals.Define("randInt", &BuiltInFnRandInt{})
-	globals.Define("sleep", &BuiltInFnSleep{})
-	globals.Define("clear", &BuiltInFnClear{})
-	globals.Define("strlen", &BuiltInFnStrLen{})
-	globals.Define("substring", &BuiltInFnSubstring{})
-	globals.Define("getch", &BuiltInFnGetch{})
+	script := &module{globals: newGlobals()}
 
 	return &Interpreter{
-		env:     globals,
-		globals: globals,
+		depth:   1, // the script itself, as in the VM
+		env:     script.globals,
+		module:  script,
 		locals:  make(map[ast.Expr]int),
+		imports: builtin.NewImports(),
+		stdout:  os.Stdout,
+		stdin:   bufio.NewReader(os.Stdin),
 	}
 }
 
+// SetOutput redirects everything the program prints (print, clear, input prompts).
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.stdout = w
+}
+
+// SetInput replaces the reader used by input() and getch().
+func (i *Interpreter) SetInput(r io.Reader) {
+	i.stdin = bufio.NewReader(r)
+}
+
+// Stdout and Stdin make the interpreter a builtin.Host.
+func (i *Interpreter) Stdout() io.Writer {
+	return i.stdout
+}
+
+func (i *Interpreter) Stdin() *bufio.Reader {
+	return i.stdin
+}
+
+// Call runs callee for a native that takes a callback, such as a list's map.
+func (i *Interpreter) Call(callee any, arguments ...any) (any, error) {
+	function, ok := callee.(Callable)
+	if !ok {
+		return nil, NewRuntimeError(diagnostic.CodeType, "can only call functions and classes")
+	}
+
+	if len(arguments) != function.Arity() {
+		return nil, NewRuntimeError(diagnostic.CodeArity, fmt.Sprintf("expected %d arguments but got %d", function.Arity(), len(arguments)))
+	}
+
+	return function.Call(i, arguments)
+}
+
+func (i *Interpreter) Method(object any, name string) (any, bool) {
+	instance, ok := object.(*Instance)
+	if !ok {
+		return nil, false
+	}
+
+	method := instance.class.findMethod(name)
+	if method == nil {
+		return nil, false
+	}
+
+	return method.bind(instance), true
+}
+
+func (i *Interpreter) Fields(object any) (map[string]any, bool) {
+	instance, ok := object.(*Instance)
+	if !ok {
+		return nil, false
+	}
+
+	return instance.fields, true
+}
+
 func (i *Interpreter) Interpret(program []ast.Stmt) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = NewRuntimeErrorWithLog(fmt.Sprint(r))
+			err = NewRuntimeError(diagnostic.CodeRuntime, fmt.Sprint(r))
+		}
+
+		if rtErr, ok := err.(*RuntimeError); ok {
+			rtErr.leaveFunction("<script>", i.module.file)
+			log.Debug("Runtime error", log.E(rtErr))
 		}
 	}()
 
@@ -71,15 +135,15 @@ func (i *Interpreter) execute(stmt ast.Stmt) error {
 		return nil
 	}
 
-	return result.(error)
+	return locateError(result.(error), stmt.Pos())
 }
 
 func (i *Interpreter) evaluate(expr ast.Expr) (any, error) {
 	if v, ok := expr.Accept(i).(*valueAndError); ok {
-		return v.value, v.err
+		return v.value, locateError(v.err, expr.Pos())
 	}
 
-	return nil, NewRuntimeErrorWithLog("interpreter error")
+	return nil, NewRuntimeError(diagnostic.CodeRuntime, "interpreter error")
 }
 
 func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) any {
@@ -94,7 +158,7 @@ func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) any {
 		return &valueAndError{value, err}
 	}
 
-	err = i.globals.Assign(expr.Name.Lexeme, value)
+	err = i.module.globals.Assign(expr.Name.Lexeme, value)
 	if err != nil {
 		return &valueAndError{nil, err}
 	}
@@ -119,7 +183,7 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) any {
 				return &valueAndError{ls + rs, nil}
 			}
 
-			return &valueAndError{nil, NewRuntimeErrorWithLog("can only concatenate string to string")}
+			return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "can only concatenate string to string")}
 		}
 
 		return binaryNumericOp(
@@ -175,7 +239,7 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) any {
 		return &valueAndError{util.IsNotEqual(left, right), nil}
 	}
 
-	return &valueAndError{nil, NewRuntimeErrorWithLog("unknown binary operator")}
+	return &valueAndError{nil, NewRuntimeError(diagnostic.CodeRuntime, "unknown binary operator")}
 }
 
 func (i *Interpreter) VisitCallExpr(expr *ast.Call) any {
@@ -195,7 +259,7 @@ func (i *Interpreter) VisitCallExpr(expr *ast.Call) any {
 
 	if function, ok := callee.(Callable); ok {
 		if len(arguments) != function.Arity() {
-			return &valueAndError{nil, NewRuntimeErrorWithLog(fmt.Sprintf("expected %d arguments but got %d", function.Arity(), len(arguments)))}
+			return &valueAndError{nil, NewRuntimeError(diagnostic.CodeArity, fmt.Sprintf("expected %d arguments but got %d", function.Arity(), len(arguments)))}
 		}
 
 		value, err := function.Call(i, arguments)
@@ -203,7 +267,7 @@ func (i *Interpreter) VisitCallExpr(expr *ast.Call) any {
 		return &valueAndError{value, err}
 	}
 
-	return &valueAndError{nil, NewRuntimeErrorWithLog("can only call functions and classes")}
+	return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "can only call functions and classes")}
 
 }
 
@@ -219,7 +283,27 @@ func (i *Interpreter) VisitGetExpr(expr *ast.Get) any {
 		return &valueAndError{value, err}
 	}
 
-	return &valueAndError{nil, NewRuntimeErrorWithLog("only instances have properties")}
+	if module, ok := object.(*builtin.Module); ok {
+		value, err := moduleMember(module, expr.Name.Lexeme)
+
+		return &valueAndError{value, err}
+	}
+
+	if record, ok := object.(builtin.Record); ok {
+		if value, ok := record.Field(expr.Name.Lexeme); ok {
+			return &valueAndError{value, nil}
+		}
+	}
+
+	if builtinObject, ok := object.(builtin.Object); ok {
+		if method, ok := builtinObject.Method(expr.Name.Lexeme); ok {
+			return &valueAndError{&NativeFunction{native: method}, nil}
+		}
+
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeUndefined, "undefined "+builtinObject.TypeName()+" method: "+expr.Name.Lexeme)}
+	}
+
+	return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "only instances have properties")}
 }
 
 func (i *Interpreter) VisitGroupingExpr(expr *ast.Grouping) any {
@@ -228,6 +312,67 @@ func (i *Interpreter) VisitGroupingExpr(expr *ast.Grouping) any {
 	return &valueAndError{v, err}
 }
 
+func (i *Interpreter) VisitIndexExpr(expr *ast.Index) any {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return &valueAndError{nil, err}
+	}
+
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return &valueAndError{nil, err}
+	}
+
+	value, err := builtin.GetIndex(object, index)
+	if err != nil {
+		return &valueAndError{nil, nativeError(err)}
+	}
+
+	return &valueAndError{value, nil}
+}
+
+func (i *Interpreter) VisitIndexSetExpr(expr *ast.IndexSet) any {
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return &valueAndError{nil, err}
+	}
+
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return &valueAndError{nil, err}
+	}
+
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return &valueAndError{nil, err}
+	}
+
+	if err := builtin.SetIndex(object, index, value); err != nil {
+		return &valueAndError{nil, nativeError(err)}
+	}
+
+	return &valueAndError{value, nil}
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *ast.Lambda) any {
+	return &valueAndError{&Function{declaration: expr.Function, clousure: i.env, module: i.module}, nil}
+}
+
+func (i *Interpreter) VisitListExpr(expr *ast.List) any {
+	elements := make([]any, 0, len(expr.Elements))
+
+	for _, elementExpr := range expr.Elements {
+		element, err := i.evaluate(elementExpr)
+		if err != nil {
+			return &valueAndError{nil, err}
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &valueAndError{builtin.NewList(elements), nil}
+}
+
 func (i *Interpreter) VisitLiteralExpr(expr *ast.Literal) any {
 	return &valueAndError{expr.Value, nil}
 }
@@ -256,6 +401,28 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) any {
 	return &valueAndError{right, nil}
 }
 
+func (i *Interpreter) VisitMapExpr(expr *ast.Map) any {
+	m := builtin.NewMap()
+
+	for index := range expr.Keys {
+		key, err := i.evaluate(expr.Keys[index])
+		if err != nil {
+			return &valueAndError{nil, err}
+		}
+
+		value, err := i.evaluate(expr.Values[index])
+		if err != nil {
+			return &valueAndError{nil, err}
+		}
+
+		if err := m.Set(key, value); err != nil {
+			return &valueAndError{nil, locateError(nativeError(err), expr.Keys[index].Pos())}
+		}
+	}
+
+	return &valueAndError{m, nil}
+}
+
 func (i *Interpreter) VisitSetExpr(expr *ast.Set) any {
 	value, err := i.evaluate(expr.Value)
 	if err != nil {
@@ -273,7 +440,7 @@ func (i *Interpreter) VisitSetExpr(expr *ast.Set) any {
 		return &valueAndError{value, nil}
 	}
 
-	return &valueAndError{nil, NewRuntimeErrorWithLog("only instances have fields")}
+	return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "only instances have fields")}
 }
 
 func (i *Interpreter) VisitSuperExpr(expr *ast.Super) any {
@@ -290,17 +457,17 @@ func (i *Interpreter) VisitSuperExpr(expr *ast.Super) any {
 
 	cls, ok := superclass.(*Class)
 	if !ok {
-		return &valueAndError{nil, NewRuntimeErrorWithLog("super must be a class")}
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "super must be a class")}
 	}
 
 	instance, ok := object.(*Instance)
 	if !ok {
-		return &valueAndError{nil, NewRuntimeErrorWithLog("this must be an instance")}
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "this must be an instance")}
 	}
 
 	method := cls.findMethod(expr.Method.Lexeme)
 	if method == nil {
-		return &valueAndError{nil, NewRuntimeErrorWithLog("undefined property: " + expr.Method.Lexeme)}
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeUndefined, "undefined property: "+expr.Method.Lexeme)}
 	}
 
 	return &valueAndError{method.bind(instance), nil}
@@ -341,12 +508,12 @@ func (i *Interpreter) VisitUnaryExpr(expr *ast.Unary) any {
 			return &valueAndError{-v, nil}
 		}
 
-		return &valueAndError{nil, NewRuntimeErrorWithLog("operand must be a number")}
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "operand must be a number")}
 	case scanner.BANG:
 		return &valueAndError{!util.IsTruthy(right), nil}
 	}
 
-	return &valueAndError{nil, NewRuntimeErrorWithLog("unknown unary operator")}
+	return &valueAndError{nil, NewRuntimeError(diagnostic.CodeRuntime, "unknown unary operator")}
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *ast.Variable) any {
@@ -359,7 +526,7 @@ func (i *Interpreter) lookupVariable(name *scanner.Token, expr ast.Expr) (any, e
 		return i.env.GetAt(distance, name.Lexeme)
 	}
 
-	return i.globals.Get(name.Lexeme)
+	return i.module.globals.Get(name.Lexeme)
 }
 
 func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) any {
@@ -394,7 +561,7 @@ func (i *Interpreter) VisitClassStmt(stmt *ast.Class) any {
 		if sc, ok := v.(*Class); ok {
 			superclass = sc
 		} else {
-			return NewRuntimeErrorWithLog("superclass must be a class")
+			return NewRuntimeError(diagnostic.CodeType, "superclass must be a class")
 		}
 	}
 
@@ -410,6 +577,7 @@ func (i *Interpreter) VisitClassStmt(stmt *ast.Class) any {
 		function := &Function{
 			declaration:   method,
 			clousure:      i.env,
+			module:        i.module,
 			isInitializer: method.Name.Lexeme == "init",
 		}
 		methods[method.Name.Lexeme] = function
@@ -437,7 +605,7 @@ func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) any {
 }
 
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) any {
-	function := &Function{declaration: stmt, clousure: i.env}
+	function := &Function{declaration: stmt, clousure: i.env, module: i.module}
 	i.env.Define(stmt.Name.Lexeme, function)
 
 	return nil
@@ -474,7 +642,7 @@ func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) any {
 		return err
 	}
 
-	fmt.Println(value)
+	fmt.Fprintln(i.stdout, value)
 
 	return nil
 }
@@ -529,17 +697,90 @@ func (i *Interpreter) VisitWhileStmt(stmt *ast.While) any {
 				break
 			}
 
-			if _, ok := err.(*continueSignal); ok {
-				continue
+			if _, ok := err.(*continueSignal); !ok {
+				return err
 			}
+		}
 
-			return err
+		if stmt.Increment != nil {
+			if _, err := i.evaluate(stmt.Increment); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+func (i *Interpreter) VisitThrowStmt(stmt *ast.Throw) any {
+	value, err := i.evaluate(stmt.Value)
+	if err != nil {
+		return err
+	}
+
+	return newThrow(value)
+}
+
+// VisitTryStmt catches runtime errors and thrown values, not the signals
+// of break, continue and return, which pass through after finally has run.
+func (i *Interpreter) VisitTryStmt(stmt *ast.Try) any {
+	err := i.execute(stmt.Body)
+
+	if rtErr, ok := err.(*RuntimeError); ok && stmt.CatchBody != nil {
+		env := NewEnvironment(i.env)
+		env.Define(stmt.CatchName.Lexeme, rtErr.caughtValue())
+
+		err = i.executeBlock([]ast.Stmt{stmt.CatchBody}, env)
+	}
+
+	if stmt.Finally != nil {
+		// leaving finally early replaces whatever left the try
+		if finallyErr := i.execute(stmt.Finally); finallyErr != nil {
+			return finallyErr
+		}
+	}
+
+	return err
+}
+
+func (i *Interpreter) VisitForInStmt(stmt *ast.ForIn) any {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return err
+	}
+
+	iterator, err := builtin.Iterate(i, iterable)
+	if err != nil {
+		return locateError(nativeError(err), stmt.Iterable.Pos())
+	}
+
+	for {
+		value, ok, err := iterator.Next(i)
+		if err != nil {
+			return locateError(nativeError(err), stmt.Iterable.Pos())
+		}
+
+		if !ok {
+			return nil
+		}
+
+		// each pass gets its own variable, so closures capture that pass's value
+		env := NewEnvironment(i.env)
+		env.Define(stmt.Name.Lexeme, value)
+
+		err = i.executeBlock([]ast.Stmt{stmt.Body}, env)
+		if err != nil {
+			if _, ok := err.(*breakSignal); ok {
+				return nil
+			}
+
+			if _, ok := err.(*continueSignal); !ok {
+				return err
+			}
+		}
+	}
+}
+
 func (i *Interpreter) VisitBreakStmt(stmt *ast.Break) any {
 	return &breakSignal{}
 }
@@ -566,7 +807,7 @@ func binaryNumericOp(left, right any, opInt func(int64, int64) any, opFloat func
 	} else if lIsFloat {
 		lf = lFloat
 	} else {
-		return &valueAndError{nil, NewRuntimeErrorWithLog("operand must be a int or float")}
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "operand must be a int or float")}
 	}
 
 	if rIsInt {
@@ -574,7 +815,7 @@ func binaryNumericOp(left, right any, opInt func(int64, int64) any, opFloat func
 	} else if rIsFloat {
 		rf = rFloat
 	} else {
-		return &valueAndError{nil, NewRuntimeErrorWithLog("operand must be a int or float")}
+		return &valueAndError{nil, NewRuntimeError(diagnostic.CodeType, "operand must be a int or float")}
 	}
 
 	return &valueAndError{opFloat(lf, rf), nil}
@@ -0,0 +1,153 @@
+package interpreter
+
+import (
+	"internal/ast"
+	"internal/builtin"
+	"internal/diagnostic"
+)
+
+// module is the global namespace of one script: the one being run or one it
+// imported. Functions keep the module they were declared in and use its
+// globals wherever they are called from.
+type module struct {
+	file    string // empty for the script being run
+	globals *Environment
+}
+
+// newGlobals makes the global environment a module starts with.
+func newGlobals() *Environment {
+	globals := NewEnvironment(nil)
+
+	for _, native := range builtin.Globals() {
+		globals.Define(native.Name, &NativeFunction{native: native})
+	}
+
+	return globals
+}
+
+// Loader finds the script an import names. path is resolved against from,
+// the file of the importing module, which is empty for the script being run.
+// It returns the file found and its parsed program.
+type Loader func(path string, from string) (file string, program []ast.Stmt, err error)
+
+// SetLoader lets the program import scripts. Without a loader only the
+// standard library modules can be imported.
+func (i *Interpreter) SetLoader(loader Loader) {
+	i.loader = loader
+}
+
+func (i *Interpreter) VisitImportStmt(stmt *ast.Import) any {
+	module, err := i.importModule(stmt.Path.Literal.(string))
+	if err != nil {
+		return err
+	}
+
+	if stmt.Alias != nil {
+		i.env.Define(stmt.Alias.Lexeme, module)
+
+		return nil
+	}
+
+	for _, name := range stmt.Names {
+		value, err := moduleMember(module, name.Lexeme)
+		if err != nil {
+			return locateError(err, ast.Offset(name.Offset))
+		}
+
+		i.env.Define(name.Lexeme, value)
+	}
+
+	return nil
+}
+
+// importModule returns the module path names, running its script the first
+// time it is imported.
+func (i *Interpreter) importModule(path string) (*builtin.Module, error) {
+	if module, ok := builtin.StdModule(path); ok {
+		return module, nil
+	}
+
+	if i.loader == nil {
+		return nil, NewRuntimeError(diagnostic.CodeImport, "cannot find module \""+path+"\"")
+	}
+
+	file, program, err := i.loader(path, i.module.file)
+	if err != nil {
+		rtErr := NewRuntimeError(diagnostic.CodeImport, err.Error())
+		rtErr.cause = err
+
+		return nil, rtErr
+	}
+
+	if module, ok := i.imports.Loaded(file); ok {
+		return module, nil
+	}
+
+	if err := i.imports.Begin(file); err != nil {
+		return nil, nativeError(err)
+	}
+
+	module, err := i.runModule(file, program)
+	i.imports.End(file, module)
+
+	return module, err
+}
+
+// runModule runs the program of file in a fresh global environment.
+func (i *Interpreter) runModule(file string, program []ast.Stmt) (*builtin.Module, error) {
+	m := &module{file: file, globals: newGlobals()}
+
+	if err := i.enterCall(); err != nil {
+		return nil, err
+	}
+	defer i.leaveCall()
+
+	err := NewResolver(i).Resolve(program)
+	if resolveErr, ok := err.(*ResolveError); ok {
+		rtErr := NewRuntimeError(resolveErr.Code, resolveErr.Message)
+		rtErr.locate(resolveErr.Offset)
+		err = rtErr
+	}
+
+	if err == nil {
+		env, importer := i.env, i.module
+		i.env, i.module = m.globals, m
+
+		for _, stmt := range program {
+			if err = i.execute(stmt); err != nil {
+				break
+			}
+		}
+
+		i.env, i.module = env, importer
+	}
+
+	if err != nil {
+		if rtErr, ok := err.(*RuntimeError); ok {
+			rtErr.leaveFunction("<module>", file)
+		}
+
+		return nil, err
+	}
+
+	return builtin.NewModule(builtin.ModuleName(file), func(name string) (any, bool) {
+		value, ok := m.globals.Values[name]
+
+		return value, ok
+	}), nil
+}
+
+// moduleMember reads a member of module, wrapping a standard library native
+// in the interpreter's callable.
+func moduleMember(module *builtin.Module, name string) (any, error) {
+	value, ok := module.Member(name)
+	if !ok {
+		return nil, NewRuntimeError(diagnostic.CodeUndefined, "undefined member of "+module.String()+": "+name)
+	}
+
+	if native, ok := value.(*builtin.Native); ok {
+		return &NativeFunction{native: native}, nil
+	}
+
+	return value, nil
+}
@@ -1,8 +1,8 @@
 package interpreter
 
 import (
-	"errors"
 	"internal/ast"
+	"internal/diagnostic"
 	"internal/scanner"
 	"internal/util/log"
 )
@@ -25,7 +25,7 @@ func NewResolver(interpreter *Interpreter) *Resolver {
 func (r *Resolver) Resolve(statements []ast.Stmt) error {
 	err := r.resolveStmts(statements)
 	if err != nil {
-		log.Error("Resolve error", log.E(err))
+		log.Debug("Resolve error", log.E(err))
 	}
 
 	return err
@@ -102,6 +102,54 @@ func (r *Resolver) VisitGroupingExpr(expr *ast.Grouping) any {
 	return nil
 }
 
+func (r *Resolver) VisitIndexExpr(expr *ast.Index) any {
+	err := expr.Object.Accept(r)
+	if err != nil {
+		return err
+	}
+
+	err = expr.Index.Accept(r)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitIndexSetExpr(expr *ast.IndexSet) any {
+	err := expr.Value.Accept(r)
+	if err != nil {
+		return err
+	}
+
+	err = expr.Object.Accept(r)
+	if err != nil {
+		return err
+	}
+
+	err = expr.Index.Accept(r)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitLambdaExpr(expr *ast.Lambda) any {
+	return r.resolveFunction(expr.Function, FUNCTION)
+}
+
+func (r *Resolver) VisitListExpr(expr *ast.List) any {
+	for _, element := range expr.Elements {
+		err := element.Accept(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Resolver) VisitLiteralExpr(expr *ast.Literal) any {
 	return nil
 }
@@ -120,6 +168,22 @@ func (r *Resolver) VisitLogicalExpr(expr *ast.Logical) any {
 	return nil
 }
 
+func (r *Resolver) VisitMapExpr(expr *ast.Map) any {
+	for i := range expr.Keys {
+		err := expr.Keys[i].Accept(r)
+		if err != nil {
+			return err
+		}
+
+		err = expr.Values[i].Accept(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Resolver) VisitSetExpr(expr *ast.Set) any {
 	err := expr.Value.Accept(r)
 	if err != nil {
@@ -136,9 +200,9 @@ func (r *Resolver) VisitSetExpr(expr *ast.Set) any {
 
 func (r *Resolver) VisitSuperExpr(expr *ast.Super) any {
 	if r.currentClass == NOT_CLASS_TYPE {
-		return errors.New("cannot use 'super' outside of a class")
+		return newResolveError(diagnostic.CodeInvalidThisOrSuper, "cannot use 'super' outside of a class", expr.Pos())
 	} else if r.currentClass != SUBCLASS {
-		return errors.New("cannot use 'super' in a class with no superclass")
+		return newResolveError(diagnostic.CodeInvalidThisOrSuper, "cannot use 'super' in a class with no superclass", expr.Pos())
 	}
 
 	r.resolveLocal(expr, expr.Keyword)
@@ -148,7 +212,7 @@ func (r *Resolver) VisitSuperExpr(expr *ast.Super) any {
 
 func (r *Resolver) VisitThisExpr(expr *ast.This) any {
 	if r.currentClass == NOT_CLASS_TYPE {
-		return errors.New("cannot use 'this' outside of a class")
+		return newResolveError(diagnostic.CodeInvalidThisOrSuper, "cannot use 'this' outside of a class", expr.Pos())
 	}
 
 	r.resolveLocal(expr, expr.Keyword)
@@ -187,7 +251,7 @@ func (r *Resolver) VisitUnaryExpr(expr *ast.Unary) any {
 func (r *Resolver) VisitVariableExpr(expr *ast.Variable) any {
 	if len(r.scopes) != 0 {
 		if defined, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && !defined {
-			return errors.New("Cannot read local variable in its own initializer: " + expr.Name.Lexeme)
+			return newResolveError(diagnostic.CodeOwnInitializer, "Cannot read local variable in its own initializer: "+expr.Name.Lexeme, expr.Pos())
 		}
 	}
 
@@ -218,7 +282,7 @@ func (r *Resolver) VisitClassStmt(stmt *ast.Class) any {
 
 	if stmt.Superclass != nil {
 		if stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
-			return errors.New("a class cannot inherit from itself")
+			return newResolveError(diagnostic.CodeSelfInheritance, "a class cannot inherit from itself", stmt.Superclass.Pos())
 		}
 
 		r.currentClass = SUBCLASS
@@ -329,12 +393,12 @@ func (r *Resolver) VisitPrintStmt(stmt *ast.Print) any {
 
 func (r *Resolver) VisitReturnStmt(stmt *ast.Return) any {
 	if r.currentFunc == NOT_FUNCTION_TYPE {
-		return errors.New("cannot return from top-level code")
+		return newResolveError(diagnostic.CodeInvalidReturn, "cannot return from top-level code", stmt.Pos())
 	}
 
 	if stmt.Value != nil {
 		if r.currentFunc == INITIALIZER {
-			return errors.New("cannot return a value from an initializer")
+			return newResolveError(diagnostic.CodeInvalidReturn, "cannot return a value from an initializer", stmt.Value.Pos())
 		}
 
 		err := stmt.Value.Accept(r)
@@ -375,6 +439,83 @@ func (r *Resolver) VisitWhileStmt(stmt *ast.While) any {
 		return err
 	}
 
+	if stmt.Increment != nil {
+		err = stmt.Increment.Accept(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitThrowStmt(stmt *ast.Throw) any {
+	return stmt.Value.Accept(r)
+}
+
+func (r *Resolver) VisitTryStmt(stmt *ast.Try) any {
+	if err := stmt.Body.Accept(r); err != nil {
+		return err
+	}
+
+	if stmt.CatchBody != nil {
+		// the catch variable gets a scope of its own around the catch block
+		r.beginScope()
+
+		if err := r.declare(stmt.CatchName); err != nil {
+			return err
+		}
+		r.define(stmt.CatchName)
+
+		if err := stmt.CatchBody.Accept(r); err != nil {
+			return err
+		}
+
+		r.endScope()
+	}
+
+	if stmt.Finally != nil {
+		return stmt.Finally.Accept(r)
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitImportStmt(stmt *ast.Import) any {
+	names := stmt.Names
+	if stmt.Alias != nil {
+		names = []*scanner.Token{stmt.Alias}
+	}
+
+	for _, name := range names {
+		if err := r.declare(name); err != nil {
+			return err
+		}
+
+		r.define(name)
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitForInStmt(stmt *ast.ForIn) any {
+	if err := stmt.Iterable.Accept(r); err != nil {
+		return err
+	}
+
+	r.beginScope()
+
+	if err := r.declare(stmt.Name); err != nil {
+		return err
+	}
+	r.define(stmt.Name)
+
+	if err := stmt.Body.Accept(r); err != nil {
+		return err
+	}
+
+	r.endScope()
+
 	return nil
 }
 
@@ -400,7 +541,7 @@ func (r *Resolver) declare(name *scanner.Token) error {
 	}
 
 	if _, ok := r.scopes[len(r.scopes)-1][name.Lexeme]; ok {
-		return errors.New("Variable with this name already declared in this scope: " + name.Lexeme)
+		return newResolveError(diagnostic.CodeRedeclared, "Variable with this name already declared in this scope: "+name.Lexeme, ast.Offset(name.Offset))
 	}
 
 	scope := r.scopes[len(r.scopes)-1]
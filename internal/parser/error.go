@@ -1,24 +1,38 @@
 package parser
 
 import (
+	"fmt"
+	"internal/diagnostic"
 	"internal/scanner"
 	"internal/util/log"
 )
 
 type ParseError struct {
 	Message string
+	Code    diagnostic.Code
+	Offset  scanner.Offset
 }
 
-func NewParseErrorWithLog(message string, token *scanner.Token) *ParseError {
+func NewParseErrorWithLog(code diagnostic.Code, message string, token *scanner.Token) *ParseError {
 	err := &ParseError{
 		Message: message,
+		Code:    code,
+		Offset:  token.Offset,
 	}
 
-	log.Error("Parse error", log.E(err), log.A("token", token))
+	log.Debug("Parse error", log.E(err), log.A("token", token))
 
 	return err
 }
 
 func (e *ParseError) Error() string {
-	return e.Message
+	return fmt.Sprintf("[line %d, column %d] Error: %s", e.Offset.Line, e.Offset.Column, e.Message)
+}
+
+func (e *ParseError) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{
+		Code:    e.Code,
+		Message: e.Message,
+		Span:    diagnostic.Span(e.Offset),
+	}
 }
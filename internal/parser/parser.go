@@ -2,8 +2,11 @@ package parser
 
 import (
 	"internal/ast"
+	"internal/diagnostic"
 	"internal/scanner"
 	"slices"
+	"strings"
+	"unicode/utf8"
 )
 
 type Parser struct {
@@ -55,13 +58,121 @@ func (p *Parser) declaration() (ast.Stmt, error) {
 		return p.classDecl()
 	}
 
-	if p.match(scanner.FUN) {
+	if p.match(scanner.IMPORT) {
+		return p.importDecl()
+	}
+
+	if p.match(scanner.FROM) {
+		return p.fromImportDecl()
+	}
+
+	// 'fun (' starts a function expression, not a declaration
+	if p.check(scanner.FUN) && !p.checkNext(scanner.LEFT_PAREN) {
+		p.advance()
+
 		return p.funDecl()
 	}
 
 	return p.statement()
 }
 
+// importDecl parses `import "path" as name;`. Without `as`, the module is
+// bound to the last element of its path.
+func (p *Parser) importDecl() (*ast.Import, error) {
+	keyword := p.previous()
+
+	path, err := p.consumeOrError(scanner.STRING, "Expect module path after 'import'.")
+	if err != nil {
+		return nil, err
+	}
+
+	var alias *scanner.Token
+
+	if p.match(scanner.AS) {
+		alias, err = p.consumeOrError(scanner.IDENTIFIER, "Expect name after 'as'.")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		alias = moduleName(path)
+		if alias == nil {
+			return nil, NewParseErrorWithLog(diagnostic.CodeSyntax, "Expect 'as' and a name for module "+path.Lexeme+".", path)
+		}
+	}
+
+	_, err = p.consumeOrError(scanner.SEMICOLON, "Expect ';' after import.")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Import{
+		Keyword: keyword,
+		Path:    path,
+		Alias:   alias,
+		Offset:  p.spanFrom(ast.Offset(keyword.Offset)),
+	}, nil
+}
+
+// fromImportDecl parses `from "path" import a, b;`.
+func (p *Parser) fromImportDecl() (*ast.Import, error) {
+	keyword := p.previous()
+
+	path, err := p.consumeOrError(scanner.STRING, "Expect module path after 'from'.")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.consumeOrError(scanner.IMPORT, "Expect 'import' after module path.")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]*scanner.Token, 0)
+
+	for {
+		name, err := p.consumeOrError(scanner.IDENTIFIER, "Expect name to import.")
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+
+	_, err = p.consumeOrError(scanner.SEMICOLON, "Expect ';' after import.")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Import{
+		Keyword: keyword,
+		Path:    path,
+		Names:   names,
+		Offset:  p.spanFrom(ast.Offset(keyword.Offset)),
+	}, nil
+}
+
+// moduleName makes the name `import "path/to/utils";` binds, utils, or
+// returns nil if the last element of the path is not an identifier.
+func moduleName(path *scanner.Token) *scanner.Token {
+	name := path.Literal.(string)
+	name = strings.TrimSuffix(name[strings.LastIndexAny(name, "/\\")+1:], ".holang")
+
+	tokens, errs := scanner.NewScanner(name).ScanTokens()
+	if len(errs) > 0 || len(tokens) != 2 || tokens[0].TokenType != scanner.IDENTIFIER {
+		return nil
+	}
+
+	return &scanner.Token{
+		TokenType: scanner.IDENTIFIER,
+		Lexeme:    name,
+		Offset:    path.Offset,
+	}
+}
+
 func (p *Parser) varDecl() (*ast.Var, error) {
 	name, err := p.consumeOrError(scanner.IDENTIFIER, "Expect variable name.")
 	if err != nil {
@@ -111,7 +222,7 @@ func (p *Parser) classDecl() (*ast.Class, error) {
 		}
 
 		if name.Lexeme == superToken.Lexeme {
-			return nil, NewParseErrorWithLog("a class cannot inherit from itself", superToken)
+			return nil, NewParseErrorWithLog(diagnostic.CodeSelfInheritance, "a class cannot inherit from itself", superToken)
 		}
 	}
 
@@ -155,12 +266,49 @@ func (p *Parser) funDecl() (*ast.Function, error) {
 		return nil, err
 	}
 
+	return p.function(name, ast.Offset(name.Offset))
+}
+
+// function parses the parameters and body of a function after its '('.
+// name is the function's own name, or a synthetic one for a function
+// expression.
+func (p *Parser) function(name *scanner.Token, offset ast.Offset) (*ast.Function, error) {
+	parameters, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.consumeOrError(scanner.LEFT_BRACE, "Expect '{' before function body.")
+	if err != nil {
+		return nil, err
+	}
+
+	// break and continue cannot leave a function for a loop around it
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Function{
+		Name:   name,
+		Params: parameters,
+		Body:   body.Statements,
+		Offset: offset,
+	}, nil
+}
+
+// parameters parses a parameter list after its '(', up to and including the ')'.
+func (p *Parser) parameters() ([]*scanner.Token, error) {
 	parameters := make([]*scanner.Token, 0)
 
 	if !p.check(scanner.RIGHT_PAREN) {
 		for {
 			if len(parameters) >= 255 {
-				return nil, NewParseErrorWithLog("can't have more than 255 parameters", p.peek())
+				return nil, NewParseErrorWithLog(diagnostic.CodeTooManyArguments, "can't have more than 255 parameters", p.peek())
 			}
 
 			param, err := p.consumeOrError(scanner.IDENTIFIER, "Expect parameter name.")
@@ -176,27 +324,12 @@ func (p *Parser) funDecl() (*ast.Function, error) {
 		}
 	}
 
-	_, err = p.consumeOrError(scanner.RIGHT_PAREN, "Expect ')' after parameters.")
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = p.consumeOrError(scanner.LEFT_BRACE, "Expect '{' before function body.")
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := p.block()
+	_, err := p.consumeOrError(scanner.RIGHT_PAREN, "Expect ')' after parameters.")
 	if err != nil {
 		return nil, err
 	}
 
-	return &ast.Function{
-		Name:   name,
-		Params: parameters,
-		Body:   body.Statements,
-		Offset: ast.Offset(name.Offset),
-	}, nil
+	return parameters, nil
 }
 
 func (p *Parser) statement() (ast.Stmt, error) {
@@ -232,6 +365,14 @@ func (p *Parser) statement() (ast.Stmt, error) {
 		return p.returnStatement()
 	}
 
+	if p.match(scanner.THROW) {
+		return p.throwStatement()
+	}
+
+	if p.match(scanner.TRY) {
+		return p.tryStatement()
+	}
+
 	return p.exprStatement()
 }
 
@@ -390,6 +531,10 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 	var initializer ast.Stmt
 
 	if p.match(scanner.VAR) {
+		if p.check(scanner.IDENTIFIER) && p.checkNext(scanner.IN) {
+			return p.forInStatement(ast.Offset(offset))
+		}
+
 		initializer, err = p.varDecl()
 		if err != nil {
 			return nil, err
@@ -435,19 +580,6 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 	}
 
 	// desugar for loop into while loop
-	if increment != nil {
-		body = &ast.Block{
-			Statements: []ast.Stmt{
-				body,
-				&ast.Expression{
-					Expression: increment,
-					Offset:     ast.Offset(offset),
-				},
-			},
-			Offset: ast.Offset(offset),
-		}
-	}
-
 	if condition == nil {
 		condition = &ast.Literal{Value: true}
 	}
@@ -455,6 +587,7 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 	body = &ast.While{
 		Condition: condition,
 		Body:      body,
+		Increment: increment,
 		Offset:    ast.Offset(offset),
 	}
 
@@ -471,11 +604,40 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 	return body, nil
 }
 
+// forInStatement parses the rest of `for (var name in iterable) body`, after
+// the 'var'.
+func (p *Parser) forInStatement(offset ast.Offset) (*ast.ForIn, error) {
+	name := p.advance()
+	p.advance() // in
+
+	iterable, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.consumeOrError(scanner.RIGHT_PAREN, "Expect ')' after for-in iterable.")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ForIn{
+		Name:     name,
+		Iterable: iterable,
+		Body:     body,
+		Offset:   offset,
+	}, nil
+}
+
 func (p *Parser) breakStatement() (*ast.Break, error) {
 	offset := p.previous().Offset
 
 	if p.loopDepth == 0 {
-		return nil, NewParseErrorWithLog("break statement not within a loop", p.previous())
+		return nil, NewParseErrorWithLog(diagnostic.CodeOutsideLoop, "break statement not within a loop", p.previous())
 	}
 
 	_, err := p.consumeOrError(scanner.SEMICOLON, "Expect ';' after break.")
@@ -492,7 +654,7 @@ func (p *Parser) continueStatement() (*ast.Continue, error) {
 	offset := p.previous().Offset
 
 	if p.loopDepth == 0 {
-		return nil, NewParseErrorWithLog("continue statement not within a loop", p.previous())
+		return nil, NewParseErrorWithLog(diagnostic.CodeOutsideLoop, "continue statement not within a loop", p.previous())
 	}
 
 	_, err := p.consumeOrError(scanner.SEMICOLON, "Expect ';' after continue.")
@@ -530,6 +692,81 @@ func (p *Parser) returnStatement() (*ast.Return, error) {
 	}, nil
 }
 
+func (p *Parser) throwStatement() (*ast.Throw, error) {
+	keyword := p.previous()
+
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.consumeOrError(scanner.SEMICOLON, "Expect ';' after thrown value.")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Throw{
+		Keyword: keyword,
+		Value:   value,
+		Offset:  ast.Offset(keyword.Offset),
+	}, nil
+}
+
+func (p *Parser) tryStatement() (*ast.Try, error) {
+	offset := p.previous().Offset
+	stmt := &ast.Try{Offset: ast.Offset(offset)}
+
+	body, err := p.blockAfter("Expect '{' after 'try'.")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Body = body
+
+	if p.match(scanner.CATCH) {
+		_, err := p.consumeOrError(scanner.LEFT_PAREN, "Expect '(' after 'catch'.")
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.CatchName, err = p.consumeOrError(scanner.IDENTIFIER, "Expect catch variable name.")
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.consumeOrError(scanner.RIGHT_PAREN, "Expect ')' after catch variable.")
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.CatchBody, err = p.blockAfter("Expect '{' before catch body.")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.match(scanner.FINALLY) {
+		stmt.Finally, err = p.blockAfter("Expect '{' after 'finally'.")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.CatchBody == nil && stmt.Finally == nil {
+		return nil, NewParseErrorWithLog(diagnostic.CodeSyntax, "Expect 'catch' or 'finally' after try block.", p.unexpected())
+	}
+
+	return stmt, nil
+}
+
+// blockAfter parses a block whose opening brace has not been consumed yet.
+func (p *Parser) blockAfter(message string) (*ast.Block, error) {
+	if _, err := p.consumeOrError(scanner.LEFT_BRACE, message); err != nil {
+		return nil, err
+	}
+
+	return p.block()
+}
+
 func (p *Parser) expression() (ast.Expr, error) {
 	return p.assignment()
 }
@@ -553,8 +790,9 @@ func (p *Parser) assignment() (ast.Expr, error) {
 			name := variable.Name
 
 			return &ast.Assign{
-				Name:  name,
-				Value: value,
+				Name:   name,
+				Value:  value,
+				Offset: p.spanFrom(expr.Pos()),
 			}, nil
 		}
 
@@ -567,7 +805,17 @@ func (p *Parser) assignment() (ast.Expr, error) {
 			}, nil
 		}
 
-		return nil, NewParseErrorWithLog("invalid assignment target", equals)
+		if index, ok := expr.(*ast.Index); ok {
+			return &ast.IndexSet{
+				Object:  index.Object,
+				Bracket: index.Bracket,
+				Index:   index.Index,
+				Value:   value,
+				Offset:  index.Offset,
+			}, nil
+		}
+
+		return nil, NewParseErrorWithLog(diagnostic.CodeInvalidAssignmentTarget, "invalid assignment target", equals)
 	}
 
 	return expr, nil
@@ -606,7 +854,7 @@ func (p *Parser) ternary() (ast.Expr, error) {
 			Mid:            mid,
 			SecondOperator: secondOp,
 			Right:          right,
-			Offset:         ast.Offset(firstOp.Offset),
+			Offset:         p.spanFrom(expr.Pos()),
 		}, nil
 	}
 
@@ -632,7 +880,7 @@ func (p *Parser) logicOr() (ast.Expr, error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(expr.Pos()),
 		}
 	}
 
@@ -658,7 +906,7 @@ func (p *Parser) logicAnd() (ast.Expr, error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(expr.Pos()),
 		}
 	}
 
@@ -684,7 +932,7 @@ func (p *Parser) equality() (ast.Expr, error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(expr.Pos()),
 		}
 	}
 
@@ -710,7 +958,7 @@ func (p *Parser) comparison() (ast.Expr, error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(expr.Pos()),
 		}
 	}
 
@@ -736,7 +984,7 @@ func (p *Parser) term() (ast.Expr, error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(expr.Pos()),
 		}
 	}
 
@@ -762,7 +1010,7 @@ func (p *Parser) factor() (ast.Expr, error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(expr.Pos()),
 		}
 	}
 
@@ -781,7 +1029,7 @@ func (p *Parser) unary() (ast.Expr, error) {
 		return &ast.Unary{
 			Operator: operator,
 			Right:    right,
-			Offset:   ast.Offset(operator.Offset),
+			Offset:   p.spanFrom(ast.Offset(operator.Offset)),
 		}, nil
 	}
 
@@ -811,6 +1059,25 @@ func (p *Parser) call() (ast.Expr, error) {
 				Name:   name,
 				Offset: ast.Offset(name.Offset),
 			}
+		} else if p.match(scanner.LEFT_BRACKET) {
+			bracket := p.previous()
+
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = p.consumeOrError(scanner.RIGHT_BRACKET, "Expect ']' after index.")
+			if err != nil {
+				return nil, err
+			}
+
+			expr = &ast.Index{
+				Object:  expr,
+				Bracket: bracket,
+				Index:   index,
+				Offset:  p.spanFrom(expr.Pos()),
+			}
 		} else {
 			break
 		}
@@ -838,7 +1105,7 @@ func (p *Parser) finishCall(callee ast.Expr) (ast.Expr, error) {
 	}
 
 	if len(arguments) >= 255 {
-		return nil, NewParseErrorWithLog("can't have more than 255 arguments", p.peek())
+		return nil, NewParseErrorWithLog(diagnostic.CodeTooManyArguments, "can't have more than 255 arguments", p.peek())
 	}
 
 	paren, err := p.consumeOrError(scanner.RIGHT_PAREN, "Expect ')' after arguments.")
@@ -850,7 +1117,7 @@ func (p *Parser) finishCall(callee ast.Expr) (ast.Expr, error) {
 		Callee:    callee,
 		Paren:     paren,
 		Arguments: arguments,
-		Offset:    ast.Offset(paren.Offset),
+		Offset:    p.spanFrom(callee.Pos()),
 	}, nil
 }
 
@@ -899,6 +1166,29 @@ func (p *Parser) primary() (ast.Expr, error) {
 		}, nil
 	}
 
+	if p.match(scanner.FUN) {
+		keyword := p.previous()
+
+		_, err := p.consumeOrError(scanner.LEFT_PAREN, "Expect '(' after 'fun'.")
+		if err != nil {
+			return nil, err
+		}
+
+		function, err := p.function(syntheticName(keyword), ast.Offset(offset))
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.Lambda{
+			Function: function,
+			Offset:   p.spanFrom(ast.Offset(offset)),
+		}, nil
+	}
+
+	if p.check(scanner.LEFT_PAREN) && p.isArrowFunction() {
+		return p.arrowFunction(ast.Offset(offset))
+	}
+
 	if p.match(scanner.LEFT_PAREN) {
 		expr, err := p.expression()
 
@@ -914,10 +1204,19 @@ func (p *Parser) primary() (ast.Expr, error) {
 
 		return &ast.Grouping{
 			Expression: expr,
-			Offset:     ast.Offset(offset),
+			Offset:     p.spanFrom(ast.Offset(offset)),
 		}, nil
 	}
 
+	if p.match(scanner.LEFT_BRACKET) {
+		return p.listLiteral(ast.Offset(offset))
+	}
+
+	// A '{' that starts a statement is a block; statement() sees it first.
+	if p.match(scanner.LEFT_BRACE) {
+		return p.mapLiteral(ast.Offset(offset))
+	}
+
 	if p.match(scanner.SUPER) {
 		keyword := p.previous()
 
@@ -934,11 +1233,149 @@ func (p *Parser) primary() (ast.Expr, error) {
 		return &ast.Super{
 			Keyword: keyword,
 			Method:  method,
-			Offset:  ast.Offset(offset),
+			Offset:  p.spanFrom(ast.Offset(offset)),
 		}, nil
 	}
 
-	return nil, NewParseErrorWithLog("expect expression", p.peek())
+	return nil, NewParseErrorWithLog(diagnostic.CodeSyntax, "expect expression", p.unexpected())
+}
+
+// isArrowFunction looks ahead from a '(' for a parameter list followed by
+// '=>', which tells an arrow function from a grouping.
+func (p *Parser) isArrowFunction() bool {
+	i := p.current + 1
+
+	if p.tokenTypeAt(i) != scanner.RIGHT_PAREN {
+		for {
+			if p.tokenTypeAt(i) != scanner.IDENTIFIER {
+				return false
+			}
+
+			i++
+
+			if p.tokenTypeAt(i) != scanner.COMMA {
+				break
+			}
+
+			i++
+		}
+	}
+
+	return p.tokenTypeAt(i) == scanner.RIGHT_PAREN && p.tokenTypeAt(i+1) == scanner.ARROW
+}
+
+// arrowFunction parses `(params) => expression`, a function that returns the
+// expression.
+func (p *Parser) arrowFunction(start ast.Offset) (*ast.Lambda, error) {
+	paren := p.advance()
+
+	parameters, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+
+	arrow := p.advance()
+
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	body := &ast.Return{
+		Keyword: arrow,
+		Value:   value,
+		Offset:  value.Pos(),
+	}
+
+	return &ast.Lambda{
+		Function: &ast.Function{
+			Name:   syntheticName(paren),
+			Params: parameters,
+			Body:   []ast.Stmt{body},
+			Offset: start,
+		},
+		Offset: p.spanFrom(start),
+	}, nil
+}
+
+// syntheticName names a function expression after the token it starts at.
+func syntheticName(at *scanner.Token) *scanner.Token {
+	return &scanner.Token{
+		TokenType: scanner.IDENTIFIER,
+		Lexeme:    "lambda",
+		Offset:    at.Offset,
+	}
+}
+
+// listLiteral parses the elements of a list after its opening '['. A
+// trailing comma is allowed.
+func (p *Parser) listLiteral(start ast.Offset) (*ast.List, error) {
+	elements := make([]ast.Expr, 0)
+
+	for !p.check(scanner.RIGHT_BRACKET) && !p.isAtEnd() {
+		element, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, element)
+
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+
+	_, err := p.consumeOrError(scanner.RIGHT_BRACKET, "Expect ']' after list elements.")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.List{
+		Elements: elements,
+		Offset:   p.spanFrom(start),
+	}, nil
+}
+
+// mapLiteral parses the entries of a map after its opening '{'. A trailing
+// comma is allowed.
+func (p *Parser) mapLiteral(start ast.Offset) (*ast.Map, error) {
+	keys := make([]ast.Expr, 0)
+	values := make([]ast.Expr, 0)
+
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		key, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.consumeOrError(scanner.COLON, "Expect ':' after map key.")
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+
+	_, err := p.consumeOrError(scanner.RIGHT_BRACE, "Expect '}' after map entries.")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Map{
+		Keys:   keys,
+		Values: values,
+		Offset: p.spanFrom(start),
+	}, nil
 }
 
 func (p *Parser) synchronize() {
@@ -951,7 +1388,8 @@ func (p *Parser) synchronize() {
 
 		switch p.peek().TokenType {
 		case scanner.CLASS, scanner.FUN, scanner.VAR, scanner.FOR,
-			scanner.IF, scanner.WHILE, scanner.PRINT, scanner.RETURN:
+			scanner.IF, scanner.WHILE, scanner.PRINT, scanner.RETURN,
+			scanner.THROW, scanner.TRY:
 			return
 		}
 
@@ -980,6 +1418,23 @@ func (p *Parser) check(t scanner.TokenType) bool {
 	return p.peek().TokenType == t
 }
 
+// checkNext is check for the token after the current one.
+func (p *Parser) checkNext(t scanner.TokenType) bool {
+	if p.current+1 >= len(p.tokens) {
+		return false
+	}
+
+	return p.tokens[p.current+1].TokenType == t
+}
+
+func (p *Parser) tokenTypeAt(i int) scanner.TokenType {
+	if i >= len(p.tokens) {
+		return scanner.EOF
+	}
+
+	return p.tokens[i].TokenType
+}
+
 func (p *Parser) advance() *scanner.Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -1000,10 +1455,44 @@ func (p *Parser) previous() *scanner.Token {
 	return &p.tokens[p.current-1]
 }
 
+// spanFrom extends start to the end of the last consumed token, so an
+// expression's span covers all of its source text.
+func (p *Parser) spanFrom(start ast.Offset) ast.Offset {
+	end := p.previous().Offset
+	start.Length = end.Index + end.Length - start.Index
+
+	return start
+}
+
 func (p *Parser) consumeOrError(t scanner.TokenType, message string) (*scanner.Token, error) {
 	if p.check(t) {
 		return p.advance(), nil
 	}
 
-	return nil, NewParseErrorWithLog(message, p.peek())
+	return nil, NewParseErrorWithLog(diagnostic.CodeSyntax, message, p.unexpected())
+}
+
+// unexpected is the token an error about what should come next points at.
+// At the end of the file that is the empty span just after the last token,
+// so a missing ';' is shown on the line it belongs to rather than the one
+// past the end.
+func (p *Parser) unexpected() *scanner.Token {
+	if !p.isAtEnd() || p.current == 0 {
+		return p.peek()
+	}
+
+	last := p.previous()
+	offset := last.Offset
+
+	lines := strings.Split(last.Lexeme, "\n")
+	if len(lines) > 1 {
+		offset.Line += len(lines) - 1
+		offset.Column = 1
+	}
+
+	offset.Column += utf8.RuneCountInString(lines[len(lines)-1])
+	offset.Index += offset.Length
+	offset.Length = 0
+
+	return &scanner.Token{TokenType: scanner.EOF, Offset: offset}
 }
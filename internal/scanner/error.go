@@ -2,27 +2,36 @@ package scanner
 
 import (
 	"fmt"
+	"internal/diagnostic"
 	"internal/util/log"
 )
 
 type ScanError struct {
 	Message string
-	Line    int
-	Where   string
+	Code    diagnostic.Code
+	Offset  Offset
 }
 
-func NewScanErrorWithLog(message string, line int, where string) *ScanError {
+func NewScanErrorWithLog(code diagnostic.Code, message string, offset Offset) *ScanError {
 	err := &ScanError{
 		Message: message,
-		Line:    line,
-		Where:   where,
+		Code:    code,
+		Offset:  offset,
 	}
 
-	log.Error("Scan error", log.E(err))
+	log.Debug("Scan error", log.E(err))
 
 	return err
 }
 
 func (e *ScanError) Error() string {
-	return fmt.Sprintf("[line %d] Error at %s: %s", e.Line, e.Where, e.Message)
+	return fmt.Sprintf("[line %d, column %d] Error: %s", e.Offset.Line, e.Offset.Column, e.Message)
+}
+
+func (e *ScanError) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{
+		Code:    e.Code,
+		Message: e.Message,
+		Span:    diagnostic.Span(e.Offset),
+	}
 }
@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"internal/diagnostic"
 	"internal/util/log"
 	"strconv"
 	"strings"
@@ -14,12 +15,17 @@ type Scanner struct {
 	current int
 	line    int
 
+	lineStart   int // index of the first rune of the current line
+	startLine   int // line and column where the current token starts
+	startColumn int
+
 	tokens []Token
 }
 
 func NewScanner(source string) *Scanner {
 	return &Scanner{
 		source: []rune(source),
+		line:   1,
 	}
 }
 
@@ -27,14 +33,14 @@ func (s *Scanner) ScanTokens() ([]Token, []error) {
 	errors := make([]error, 0)
 
 	for !s.isAtEnd() {
-		s.start = s.current
+		s.markStart()
 
 		if err := s.scanToken(); err != nil {
 			errors = append(errors, err)
 		}
 	}
 
-	s.start = s.current
+	s.markStart()
 	s.addToken(EOF, nil)
 
 	return s.tokens, errors
@@ -47,7 +53,7 @@ func (s *Scanner) scanToken() error {
 	case ' ', '\r', '\t':
 		// Ignore whitespace.
 	case '\n':
-		s.line++
+		s.newline(s.current)
 	case '(':
 		s.addToken(LEFT_PAREN, nil)
 	case ')':
@@ -56,6 +62,10 @@ func (s *Scanner) scanToken() error {
 		s.addToken(LEFT_BRACE, nil)
 	case '}':
 		s.addToken(RIGHT_BRACE, nil)
+	case '[':
+		s.addToken(LEFT_BRACKET, nil)
+	case ']':
+		s.addToken(RIGHT_BRACKET, nil)
 	case ',':
 		s.addToken(COMMA, nil)
 	case '-':
@@ -78,11 +88,11 @@ func (s *Scanner) scanToken() error {
 		} else if s.advanceIfMatch('*') {
 			for {
 				if s.peek() == '\n' {
-					s.line++
+					s.newline(s.current + 1)
 				}
 
 				if s.isAtEnd() {
-					err := NewScanErrorWithLog("Unterminated multi-line comment", s.line, "")
+					err := s.error(diagnostic.CodeUnterminatedComment, "Unterminated multi-line comment")
 					return err
 				}
 
@@ -108,6 +118,8 @@ func (s *Scanner) scanToken() error {
 	case '=':
 		if s.advanceIfMatch('=') {
 			s.addToken(EQUAL_EQUAL, nil)
+		} else if s.advanceIfMatch('>') {
+			s.addToken(ARROW, nil)
 		} else {
 			s.addToken(EQUAL, nil)
 		}
@@ -130,7 +142,7 @@ func (s *Scanner) scanToken() error {
 		for !s.isAtEnd() {
 			ch := s.advance()
 			if ch == '\n' { // raw newline inside string literal
-				s.line++
+				s.newline(s.current)
 			}
 			if ch == '"' { // closing quote
 				// finished (we already consumed closing quote; break)
@@ -139,7 +151,7 @@ func (s *Scanner) scanToken() error {
 
 			if ch == '\\' { // escape sequence
 				if s.isAtEnd() {
-					return NewScanErrorWithLog("Unterminated escape sequence", s.line, "")
+					return s.error(diagnostic.CodeUnterminatedString, "Unterminated escape sequence")
 				}
 				esc := s.advance()
 				switch esc {
@@ -158,21 +170,21 @@ func (s *Scanner) scanToken() error {
 					hexDigits := make([]rune, 0, 4)
 					for i := 0; i < 4; i++ {
 						if s.isAtEnd() {
-							return NewScanErrorWithLog("Incomplete unicode escape (expect 4 hex digits)", s.line, "")
+							return s.error(diagnostic.CodeInvalidEscape, "Incomplete unicode escape (expect 4 hex digits)")
 						}
 						h := s.advance()
 						if !(h >= '0' && h <= '9' || h >= 'a' && h <= 'f' || h >= 'A' && h <= 'F') {
-							return NewScanErrorWithLog("Invalid unicode escape (non-hex digit)", s.line, "")
+							return s.error(diagnostic.CodeInvalidEscape, "Invalid unicode escape (non-hex digit)")
 						}
 						hexDigits = append(hexDigits, h)
 					}
 					code, err := strconv.ParseInt(string(hexDigits), 16, 32)
 					if err != nil {
-						return NewScanErrorWithLog("Invalid unicode escape: "+err.Error(), s.line, "")
+						return s.error(diagnostic.CodeInvalidEscape, "Invalid unicode escape: "+err.Error())
 					}
 					builder.WriteRune(rune(code))
 				default:
-					return NewScanErrorWithLog("Unknown escape sequence: \\"+string(esc), s.line, "")
+					return s.error(diagnostic.CodeInvalidEscape, "Unknown escape sequence: \\"+string(esc))
 				}
 				continue
 			}
@@ -181,7 +193,7 @@ func (s *Scanner) scanToken() error {
 		}
 
 		if s.isAtEnd() && (len(s.source) == 0 || s.source[s.current-1] != '"') {
-			return NewScanErrorWithLog("Unterminated string", s.line, "")
+			return s.error(diagnostic.CodeUnterminatedString, "Unterminated string")
 		}
 
 		// add token including original lexeme slice; literal is decoded content
@@ -215,7 +227,7 @@ func (s *Scanner) scanToken() error {
 			}
 
 			if dotCount > 1 {
-				err := NewScanErrorWithLog("Invalid number format: multiple decimal points", s.line, "")
+				err := s.error(diagnostic.CodeInvalidNumber, "Invalid number format: multiple decimal points")
 				return err
 			}
 
@@ -242,7 +254,7 @@ func (s *Scanner) scanToken() error {
 			return nil
 		}
 
-		err := NewScanErrorWithLog("Unexpected character: "+string(c), s.line, "")
+		err := s.error(diagnostic.CodeUnexpectedCharacter, "Unexpected character: "+string(c))
 		return err
 	}
 
@@ -256,10 +268,7 @@ func (s *Scanner) addToken(t TokenType, literal any) {
 		TokenType: t,
 		Lexeme:    string(text),
 		Literal:   literal,
-		Offset: Offset{
-			Line:  s.line,
-			Index: s.start,
-		},
+		Offset:    s.span(),
 	}
 
 	log.Debug("Token", log.S("tokenType", t.String()), log.A("token", token))
@@ -267,11 +276,37 @@ func (s *Scanner) addToken(t TokenType, literal any) {
 	s.tokens = append(s.tokens, token)
 }
 
+func (s *Scanner) markStart() {
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = s.start - s.lineStart + 1
+}
+
+// newline counts a line break; the next line starts at index next.
+func (s *Scanner) newline(next int) {
+	s.line++
+	s.lineStart = next
+}
+
+// span covers the current token from its first rune up to s.current.
+func (s *Scanner) span() Offset {
+	return Offset{
+		Line:   s.startLine,
+		Column: s.startColumn,
+		Index:  s.start,
+		Length: s.current - s.start,
+	}
+}
+
+func (s *Scanner) error(code diagnostic.Code, message string) *ScanError {
+	return NewScanErrorWithLog(code, message, s.span())
+}
+
 func (s *Scanner) addIntToken() error {
 	lexeme := string(s.source[s.start:s.current])
 	intVal, err := strconv.ParseInt(lexeme, 10, 64)
 	if err != nil {
-		scanErr := NewScanErrorWithLog("Invalid integer literal: "+err.Error(), s.line, "")
+		scanErr := s.error(diagnostic.CodeInvalidNumber, "Invalid integer literal: "+err.Error())
 		return scanErr
 	}
 
@@ -284,7 +319,7 @@ func (s *Scanner) addRealToken() error {
 	lexeme := string(s.source[s.start:s.current])
 	realVal, err := strconv.ParseFloat(lexeme, 64)
 	if err != nil {
-		scanErr := NewScanErrorWithLog("Invalid float literal: "+err.Error(), s.line, "")
+		scanErr := s.error(diagnostic.CodeInvalidNumber, "Invalid float literal: "+err.Error())
 		return scanErr
 	}
 
@@ -10,6 +10,8 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
 	DOT
 	MINUS
@@ -29,6 +31,7 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	ARROW
 
 	// Literals.
 	IDENTIFIER
@@ -55,6 +58,14 @@ const (
 	WHILE
 	BREAK
 	CONTINUE
+	THROW
+	TRY
+	CATCH
+	FINALLY
+	IN
+	IMPORT
+	FROM
+	AS
 
 	// ETC
 	COMMENT
@@ -64,16 +75,18 @@ const (
 
 var tokenNames = map[TokenType]string{
 	//0
-	LEFT_PAREN:  "LEFT_PAREN",
-	RIGHT_PAREN: "RIGHT_PAREN",
-	LEFT_BRACE:  "LEFT_BRACE",
-	RIGHT_BRACE: "RIGHT_BRACE",
-	COMMA:       "COMMA",
-	DOT:         "DOT",
-	MINUS:       "MINUS",
-	PLUS:        "PLUS",
-	SEMICOLON:   "SEMICOLON",
-	SLASH:       "SLASH",
+	LEFT_PAREN:    "LEFT_PAREN",
+	RIGHT_PAREN:   "RIGHT_PAREN",
+	LEFT_BRACE:    "LEFT_BRACE",
+	RIGHT_BRACE:   "RIGHT_BRACE",
+	LEFT_BRACKET:  "LEFT_BRACKET",
+	RIGHT_BRACKET: "RIGHT_BRACKET",
+	COMMA:         "COMMA",
+	DOT:           "DOT",
+	MINUS:         "MINUS",
+	PLUS:          "PLUS",
+	SEMICOLON:     "SEMICOLON",
+	SLASH:         "SLASH",
 	//10
 	QUESTION:      "QUESTION",
 	COLON:         "COLON",
@@ -87,6 +100,7 @@ var tokenNames = map[TokenType]string{
 	LESS:          "LESS",
 	//20
 	LESS_EQUAL:  "LESS_EQUAL",
+	ARROW:       "ARROW",
 	IDENTIFIER:  "IDENTIFIER",
 	STRING:      "STRING",
 	NUMBER_INT:  "NUMBER_INT",
@@ -111,6 +125,14 @@ var tokenNames = map[TokenType]string{
 	WHILE:         "WHILE",
 	BREAK:         "BREAK",
 	CONTINUE:      "CONTINUE",
+	THROW:         "THROW",
+	TRY:           "TRY",
+	CATCH:         "CATCH",
+	FINALLY:       "FINALLY",
+	IN:            "IN",
+	IMPORT:        "IMPORT",
+	FROM:          "FROM",
+	AS:            "AS",
 	COMMENT:       "COMMENT",
 	MULTI_COMMENT: "MULTI_COMMENT",
 	EOF:           "EOF",
@@ -138,6 +160,14 @@ var keywords = map[string]TokenType{
 	"F":        FALSE,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"in":       IN,
+	"import":   IMPORT,
+	"from":     FROM,
+	"as":       AS,
 }
 
 func (t *TokenType) String() string {
@@ -148,9 +178,13 @@ func (t *TokenType) String() string {
 	return fmt.Sprintf("UNKNOWN(%d)", *t)
 }
 
+// Offset is the span of source text a token covers; it has the same layout
+// as diagnostic.Span. Line and Column are 1-based, Index and Length count runes.
 type Offset struct {
-	Line  int
-	Index int
+	Line   int
+	Column int
+	Index  int
+	Length int
 }
 
 type Token struct {
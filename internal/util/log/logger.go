@@ -45,8 +45,10 @@ func init() {
 	encCfg2 := zap.NewDevelopmentEncoderConfig()
 	fileEncoder := zapcore.NewJSONEncoder(encCfg2)
 
+	// the console log goes to stderr so it never mixes with what a script
+	// prints, which may be piped to another program
 	core := zapcore.NewTee(
-		zapcore.NewCore(consoleEnc, zapcore.AddSync(os.Stdout), logLevel),
+		zapcore.NewCore(consoleEnc, zapcore.AddSync(os.Stderr), logLevel),
 		zapcore.NewCore(fileEncoder, zapcore.AddSync(f), logLevel),
 	)
 
@@ -0,0 +1,152 @@
+package vm
+
+import (
+	"fmt"
+	"internal/bytecode"
+	"internal/diagnostic"
+	"internal/util/log"
+)
+
+// RuntimeError describes why Interpret returned InterpretResultRuntimeError.
+// Its trace is rebuilt from each call frame's ip and its chunk's line table.
+type RuntimeError struct {
+	Message string
+	Code    diagnostic.Code
+	Trace   []diagnostic.Frame
+
+	value bytecode.Value // what a catch clause receives
+	cause error          // the loader error a failed import wraps
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+func (e *RuntimeError) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Traced(e.Code, e.Message, e.Trace)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.cause
+}
+
+// LastError returns the error from the most recent Interpret, or nil if it
+// succeeded.
+func (vm *VM) LastError() *RuntimeError {
+	return vm.err
+}
+
+// runtimeError raises a runtime error at the current instruction. A try
+// statement in an active frame catches it as an Error object; otherwise it
+// is kept for LastError and InterpretResultRuntimeError is returned for the
+// caller to pass on. Only uncaught errors are logged.
+func (vm *VM) runtimeError(code diagnostic.Code, format string, a ...any) InterpretResult {
+	return vm.raise(&RuntimeError{Message: fmt.Sprintf(format, a...), Code: code})
+}
+
+// raise is runtimeError for an error that is already built.
+func (vm *VM) raise(err *RuntimeError) InterpretResult {
+	err.Trace = vm.stackTrace()
+	err.value = vm.newErrorObject(err)
+
+	return vm.throw(err)
+}
+
+// stackTrace describes the current instruction of every active frame,
+// innermost first.
+func (vm *VM) stackTrace() []diagnostic.Frame {
+	var trace []diagnostic.Frame
+
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		function := frame.closure.Function
+
+		name := function.Name
+		if name == "" && frame.closure.module == vm.script {
+			name = "<script>"
+		} else if name == "" {
+			name = "<module>"
+		}
+
+		// ip has moved past the instruction that was executing.
+		offset := function.Chunk.OffsetAt(frame.ip - 1)
+		trace = append(trace, diagnostic.Frame{
+			Function: name,
+			File:     frame.closure.module.file,
+			Span:     diagnostic.Span(offset),
+		})
+	}
+
+	return trace
+}
+
+// throw unwinds to the innermost handler protecting the current instruction
+// of an active frame. A catch handler gets the thrown value and a finally
+// handler the error itself; err is reported if no handler is found. Frames
+// of outer run loops are left for the native that called Call to unwind.
+func (vm *VM) throw(err *RuntimeError) InterpretResult {
+	for len(vm.frames) > vm.base {
+		frame := vm.frame
+
+		// ip has moved past the instruction that raised the error.
+		if handler, ok := frame.closure.Function.Chunk.HandlerAt(frame.ip - 1); ok {
+			vm.closeUpvalues(frame.slots + handler.Depth)
+			vm.stack = vm.stack[:frame.slots+handler.Depth]
+			frame.ip = handler.Target
+
+			if handler.Finally {
+				vm.push(err)
+			} else {
+				vm.push(err.value)
+			}
+
+			return InterpretResultOK
+		}
+
+		vm.closeUpvalues(frame.slots)
+		vm.stack = vm.stack[:frame.slots]
+		vm.frames = vm.frames[:len(vm.frames)-1]
+
+		vm.frame = nil
+		if len(vm.frames) > 0 {
+			vm.frame = vm.frames[len(vm.frames)-1]
+		}
+	}
+
+	if vm.base == 0 {
+		log.Debug("Runtime error", log.E(err))
+	}
+
+	vm.err = err
+
+	return InterpretResultRuntimeError
+}
+
+// newErrorObject is what a catch clause receives for a runtime error: an
+// instance of the class Error with message and line fields.
+func (vm *VM) newErrorObject(err *RuntimeError) *Instance {
+	if vm.errorClass == nil {
+		vm.errorClass = vm.newClass("Error")
+	}
+
+	line := 0
+	if len(err.Trace) > 0 {
+		line = err.Trace[0].Span.Line
+	}
+
+	instance := vm.newInstance(vm.errorClass)
+	instance.Fields["message"] = err.Message
+	instance.Fields["line"] = int64(line)
+
+	return instance
+}
+
+// describeThrown shows an Error object by its message and anything else as
+// print would.
+func (vm *VM) describeThrown(value bytecode.Value) string {
+	if instance, ok := value.(*Instance); ok && instance.Class == vm.errorClass {
+		return fmt.Sprint(instance.Fields["message"])
+	}
+
+	return fmt.Sprint(value)
+}
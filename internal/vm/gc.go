@@ -0,0 +1,292 @@
+package vm
+
+import (
+	"internal/builtin"
+	"internal/bytecode"
+	"internal/util/log"
+	"unsafe"
+)
+
+// Collector tuning. The first collection runs once the estimated heap passes
+// GCInitialThreshold; afterwards the threshold is the surviving heap times
+// GCHeapGrowFactor, but never below GCInitialThreshold.
+const (
+	GCInitialThreshold = 1024 * 1024
+	GCHeapGrowFactor   = 2
+)
+
+// GCStats reports what the collector has done so far.
+type GCStats struct {
+	Collections      int
+	ObjectsAllocated int
+	ObjectsFreed     int
+	LiveObjects      int
+	BytesAllocated   int // estimated size of the live heap
+	NextGC           int
+}
+
+// gcState is the collector's bookkeeping. Only closures, upvalues, classes,
+// instances and bound methods are tracked in the object list and counted in
+// GCStats; module globals are roots. Strings are Go values without identity,
+// and lists and maps are created by natives outside the VM as well, so none
+// of them is tracked. The collector traces through lists and maps to reach
+// the objects inside them, and Go frees them once nothing refers to them,
+// cycles through instances included.
+type gcState struct {
+	stress    bool
+	log       bool
+	threshold int
+
+	bytesAllocated int
+	nextGC         int
+	stats          GCStats
+
+	gray       []heapObject
+	containers map[any]bool // lists and maps traced in the current collection
+}
+
+func newGCState() gcState {
+	return gcState{
+		threshold: GCInitialThreshold,
+		nextGC:    GCInitialThreshold,
+	}
+}
+
+// SetGCStress makes the VM collect before every allocation, which flushes out
+// objects that are reachable but not rooted.
+func (vm *VM) SetGCStress(stress bool) {
+	vm.gc.stress = stress
+}
+
+// SetGCLog logs every collection.
+func (vm *VM) SetGCLog(enabled bool) {
+	vm.gc.log = enabled
+}
+
+// SetGCThreshold sets the minimum heap size, in estimated bytes, that
+// triggers a collection.
+func (vm *VM) SetGCThreshold(bytes int) {
+	vm.gc.threshold = bytes
+	vm.gc.nextGC = max(vm.gc.bytesAllocated*GCHeapGrowFactor, bytes)
+}
+
+func (vm *VM) GCStats() GCStats {
+	stats := vm.gc.stats
+	stats.LiveObjects = vm.objects.Len()
+	stats.BytesAllocated = vm.gc.bytesAllocated
+	stats.NextGC = vm.gc.nextGC
+
+	return stats
+}
+
+// track registers a new heap object, collecting first if the heap has grown
+// past the threshold. Callers must keep everything the new object refers to
+// reachable from a root until track returns.
+func (vm *VM) track(objectType ObjectType, value heapObject, size int) {
+	if vm.gc.stress || vm.gc.bytesAllocated+size > vm.gc.nextGC {
+		vm.CollectGarbage()
+	}
+
+	vm.objects.Add(&Object{ObjectType: objectType, Value: value, Size: size})
+	vm.gc.bytesAllocated += size
+	vm.gc.stats.ObjectsAllocated++
+}
+
+func (vm *VM) newClosure(function *bytecode.Function, module *module) *Closure {
+	closure := NewClosure(function)
+	closure.module = module
+	vm.track(ObjectTypeClosure, closure, int(unsafe.Sizeof(*closure))+len(closure.Upvalues)*int(unsafe.Sizeof(closure)))
+
+	return closure
+}
+
+func (vm *VM) newUpvalue(location int, next *Upvalue) *Upvalue {
+	upvalue := &Upvalue{location: location, next: next}
+	vm.track(ObjectTypeUpvalue, upvalue, int(unsafe.Sizeof(*upvalue)))
+
+	return upvalue
+}
+
+func (vm *VM) newClass(name string) *Class {
+	class := NewClass(name)
+	vm.track(ObjectTypeClass, class, int(unsafe.Sizeof(*class))+len(name))
+
+	return class
+}
+
+func (vm *VM) newInstance(class *Class) *Instance {
+	instance := NewInstance(class)
+	vm.track(ObjectTypeInstance, instance, int(unsafe.Sizeof(*instance)))
+
+	return instance
+}
+
+func (vm *VM) newBoundMethod(receiver bytecode.Value, method *Closure) *BoundMethod {
+	bound := &BoundMethod{Receiver: receiver, Method: method}
+	vm.track(ObjectTypeBoundMethod, bound, int(unsafe.Sizeof(*bound)))
+
+	return bound
+}
+
+// CollectGarbage marks everything reachable from the roots and drops the
+// rest from the object list.
+func (vm *VM) CollectGarbage() {
+	before := vm.gc.bytesAllocated
+
+	vm.gc.containers = make(map[any]bool)
+	vm.markRoots()
+	vm.traceReferences()
+	freed := vm.sweep()
+
+	vm.gc.nextGC = max(vm.gc.bytesAllocated*GCHeapGrowFactor, vm.gc.threshold)
+	vm.gc.stats.Collections++
+	vm.gc.stats.ObjectsFreed += freed
+
+	if vm.gc.log {
+		log.Info("GC",
+			log.I("collection", vm.gc.stats.Collections),
+			log.I("freed", freed),
+			log.I("live", vm.objects.Len()),
+			log.I("bytesBefore", before),
+			log.I("bytesAfter", vm.gc.bytesAllocated),
+			log.I("nextGC", vm.gc.nextGC),
+		)
+	}
+}
+
+func (vm *VM) markRoots() {
+	for _, value := range vm.stack {
+		vm.markValue(value)
+	}
+
+	// modules are never unloaded, and their functions may still be called
+	for _, module := range append([]*module{vm.script}, vm.modules...) {
+		for _, value := range module.globals {
+			vm.markValue(value)
+		}
+	}
+
+	for _, frame := range vm.frames {
+		vm.markObject(frame.closure)
+	}
+
+	for upvalue := vm.openUpvalues; upvalue != nil; upvalue = upvalue.next {
+		vm.markObject(upvalue)
+	}
+
+	for _, value := range vm.nativeResults {
+		vm.markValue(value)
+	}
+
+	if vm.errorClass != nil {
+		vm.markObject(vm.errorClass)
+	}
+}
+
+func (vm *VM) markValue(value bytecode.Value) {
+	if obj, ok := value.(heapObject); ok {
+		vm.markObject(obj)
+	}
+
+	switch value := value.(type) {
+	case *builtin.List:
+		if vm.gc.containers[value] {
+			return
+		}
+
+		vm.gc.containers[value] = true
+		for _, element := range value.Elements {
+			vm.markValue(element)
+		}
+
+	case *builtin.Map:
+		if vm.gc.containers[value] {
+			return
+		}
+
+		vm.gc.containers[value] = true
+		for _, element := range value.Values() {
+			vm.markValue(element)
+		}
+
+	case *builtin.Iterator:
+		// a for-in loop keeps its iterator in a hidden local
+		vm.markValue(value.Source())
+
+	case *NativeFunction:
+		// a method read off a list or map, as in xs.map, keeps the list alive
+		vm.markValue(value.Native.Receiver)
+
+	case *RuntimeError:
+		// a finally block keeps the pending error, and its value, on the stack
+		vm.markValue(value.value)
+	}
+}
+
+func (vm *VM) markObject(obj heapObject) {
+	h := obj.gcHeader()
+	if h.marked {
+		return
+	}
+
+	h.marked = true
+	vm.gc.gray = append(vm.gc.gray, obj)
+}
+
+func (vm *VM) traceReferences() {
+	for len(vm.gc.gray) > 0 {
+		obj := vm.gc.gray[len(vm.gc.gray)-1]
+		vm.gc.gray = vm.gc.gray[:len(vm.gc.gray)-1]
+
+		vm.blackenObject(obj)
+	}
+}
+
+func (vm *VM) blackenObject(obj heapObject) {
+	switch obj := obj.(type) {
+	case *Closure:
+		for _, upvalue := range obj.Upvalues {
+			if upvalue != nil {
+				vm.markObject(upvalue)
+			}
+		}
+
+	case *Upvalue:
+		vm.markValue(obj.closed)
+
+	case *Class:
+		for _, method := range obj.Methods {
+			vm.markObject(method)
+		}
+
+	case *Instance:
+		vm.markObject(obj.Class)
+
+		for _, value := range obj.Fields {
+			vm.markValue(value)
+		}
+
+	case *BoundMethod:
+		vm.markValue(obj.Receiver)
+		vm.markObject(obj.Method)
+	}
+}
+
+func (vm *VM) sweep() int {
+	freed := vm.objects.Sweep(func(obj *Object) bool {
+		h := obj.Value.gcHeader()
+		if h.marked {
+			h.marked = false
+
+			return true
+		}
+
+		return false
+	})
+
+	for _, obj := range freed {
+		vm.gc.bytesAllocated -= obj.Size
+	}
+
+	return len(freed)
+}
@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"internal/builtin"
+	"internal/bytecode"
+)
+
+// module is the global namespace of one script: the one being run or one it
+// imported. Closures keep the module they were created in and use its
+// globals wherever they are called from.
+type module struct {
+	file    string // empty for the script being run
+	globals map[string]bytecode.Value
+}
+
+// newModule makes the globals of a module, which start with the natives.
+func newModule(file string) *module {
+	m := &module{
+		file:    file,
+		globals: make(map[string]bytecode.Value),
+	}
+
+	for _, native := range builtin.Globals() {
+		m.globals[native.Name] = &NativeFunction{Native: native}
+	}
+
+	return m
+}
+
+// Loader finds the script an import names. path is resolved against from,
+// the file of the importing module, which is empty for the script being run.
+// It returns the file found and its compiled chunk.
+type Loader func(path string, from string) (file string, chunk *bytecode.Chunk, err error)
+
+// SetLoader lets the program import scripts. Without a loader only the
+// standard library modules can be imported.
+func (vm *VM) SetLoader(loader Loader) {
+	vm.loader = loader
+}
+
+// globals are the globals of the module whose code is running.
+func (vm *VM) globals() map[string]bytecode.Value {
+	return vm.frame.closure.module.globals
+}
+
+// runModule runs chunk as the script of a new module in a nested loop, the
+// way Call runs a callback. An error the script does not catch is returned
+// for the import to raise again.
+func (vm *VM) runModule(file string, chunk *bytecode.Chunk) (*builtin.Module, *RuntimeError) {
+	m := newModule(file)
+	vm.modules = append(vm.modules, m)
+
+	outer := vm.base
+	vm.base = len(vm.frames)
+	defer func() { vm.base = outer }()
+
+	height := len(vm.stack)
+	script := vm.newClosure(&bytecode.Function{Chunk: chunk}, m)
+	vm.push(script)
+
+	if vm.call(script, 0) != InterpretResultOK || vm.run() != InterpretResultOK {
+		vm.stack = vm.stack[:height]
+
+		return nil, vm.err
+	}
+
+	// the script's own return value
+	vm.pop()
+
+	return builtin.NewModule(builtin.ModuleName(file), func(name string) (any, bool) {
+		value, ok := m.globals[name]
+
+		return value, ok
+	}), nil
+}
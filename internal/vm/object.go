@@ -1,16 +1,40 @@
 package vm
 
-import "container/list"
+import (
+	"container/list"
+	"internal/builtin"
+	"internal/bytecode"
+)
 
 type ObjectType byte
 
-// const (
-// 	ObjectTypeString ObjectType = iota
-// )
+const (
+	ObjectTypeClosure ObjectType = iota
+	ObjectTypeUpvalue
+	ObjectTypeClass
+	ObjectTypeInstance
+	ObjectTypeBoundMethod
+)
 
+// Object is the collector's record of one heap allocation. Size is the
+// estimate charged against the GC threshold when it was allocated.
 type Object struct {
 	ObjectType ObjectType
-	Value      any
+	Value      heapObject
+	Size       int
+}
+
+// header is embedded in every value the collector tracks.
+type header struct {
+	marked bool
+}
+
+func (h *header) gcHeader() *header {
+	return h
+}
+
+type heapObject interface {
+	gcHeader() *header
 }
 
 type ObjectList struct {
@@ -34,3 +58,111 @@ func (ol *ObjectList) Remove(e *list.Element) {
 func (ol *ObjectList) Clear() {
 	ol.objects.Init()
 }
+
+func (ol *ObjectList) Len() int {
+	return ol.objects.Len()
+}
+
+// Sweep removes every object keep rejects and returns the removed objects.
+func (ol *ObjectList) Sweep(keep func(obj *Object) bool) []*Object {
+	var removed []*Object
+
+	for e := ol.objects.Front(); e != nil; {
+		next := e.Next()
+		obj := e.Value.(*Object)
+
+		if !keep(obj) {
+			ol.objects.Remove(e)
+			removed = append(removed, obj)
+		}
+
+		e = next
+	}
+
+	return removed
+}
+
+// Closure is a function together with the variables it captured and the
+// module whose globals it uses.
+type Closure struct {
+	header
+	Function *bytecode.Function
+	Upvalues []*Upvalue
+
+	module *module
+}
+
+func NewClosure(function *bytecode.Function) *Closure {
+	return &Closure{
+		Function: function,
+		Upvalues: make([]*Upvalue, len(function.Upvalues)),
+	}
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+// Upvalue is a captured variable. While open it refers to a live stack slot
+// by index; once that slot goes away the value is moved into closed.
+type Upvalue struct {
+	header
+	location int
+	closed   bytecode.Value
+	isClosed bool
+	next     *Upvalue // next open upvalue, ordered by descending location
+}
+
+type Class struct {
+	header
+	Name    string
+	Methods map[string]*Closure
+}
+
+func NewClass(name string) *Class {
+	return &Class{
+		Name:    name,
+		Methods: make(map[string]*Closure),
+	}
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+type Instance struct {
+	header
+	Class  *Class
+	Fields map[string]bytecode.Value
+}
+
+func NewInstance(class *Class) *Instance {
+	return &Instance{
+		Class:  class,
+		Fields: make(map[string]bytecode.Value),
+	}
+}
+
+func (i *Instance) String() string {
+	return i.Class.Name + " instance"
+}
+
+// BoundMethod is a method looked up on an instance, remembering its receiver.
+type BoundMethod struct {
+	header
+	Receiver bytecode.Value
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+// NativeFunction is a built-in implemented in Go, shared with the interpreter.
+type NativeFunction struct {
+	Native *builtin.Native
+}
+
+func (n *NativeFunction) String() string {
+	return n.Native.String()
+}
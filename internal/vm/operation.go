@@ -2,50 +2,95 @@ package vm
 
 import (
 	"fmt"
+	"internal/builtin"
+	"internal/bytecode"
+	"internal/diagnostic"
 	"internal/util"
-	"internal/util/log"
 )
 
-var OP_FUNCS []func(vm *VM) InterpretResult = []func(vm *VM) InterpretResult{
-	// CONSTANT
-	(*VM).OP_CONSTANT,
-	(*VM).OP_TRUE,
-	(*VM).OP_FALSE,
-	(*VM).OP_NIL,
-	(*VM).OP_CONSTANT_M1,
-	(*VM).OP_CONSTANT_0,
-	(*VM).OP_CONSTANT_1,
-	(*VM).OP_CONSTANT_2,
-	(*VM).OP_CONSTANT_3,
-	(*VM).OP_CONSTANT_4,
-	(*VM).OP_CONSTANT_5,
-
-	// UNARY, TERNARY
-	(*VM).OP_NEGATE,
-	(*VM).OP_NOT,
-	// (*VM).OP_TERNARY,
-
-	// BINARY
-	(*VM).OP_ADD,
-	(*VM).OP_SUBTRACT,
-	(*VM).OP_MULTIPLY,
-	(*VM).OP_DIVIDE,
-	(*VM).OP_EQUAL,
-	(*VM).OP_NOT_EQUAL,
-	(*VM).OP_GREATER,
-	(*VM).OP_LESS,
-	(*VM).OP_GREATER_EQUAL,
-	(*VM).OP_LESS_EQUAL,
-
-	// VARIABLE
-	(*VM).OP_DEFINE_GLOBAL,
-	(*VM).OP_GET_GLOBAL,
-	(*VM).OP_SET_GLOBAL,
-
-	// SPECIAL
-	(*VM).OP_RETURN,
-	(*VM).OP_POP,
-	(*VM).OP_PRINT,
+var OP_FUNCS []func(vm *VM) InterpretResult
+
+// OP_FUNCS is filled in init because OP_IMPORT runs a nested loop, which
+// refers back to the table.
+func init() {
+	OP_FUNCS = []func(vm *VM) InterpretResult{
+		// CONSTANT
+		(*VM).OP_CONSTANT,
+		(*VM).OP_TRUE,
+		(*VM).OP_FALSE,
+		(*VM).OP_NIL,
+		(*VM).OP_CONSTANT_M1,
+		(*VM).OP_CONSTANT_0,
+		(*VM).OP_CONSTANT_1,
+		(*VM).OP_CONSTANT_2,
+		(*VM).OP_CONSTANT_3,
+		(*VM).OP_CONSTANT_4,
+		(*VM).OP_CONSTANT_5,
+
+		// UNARY, TERNARY
+		(*VM).OP_NEGATE,
+		(*VM).OP_NOT,
+		// (*VM).OP_TERNARY,
+
+		// BINARY
+		(*VM).OP_ADD,
+		(*VM).OP_SUBTRACT,
+		(*VM).OP_MULTIPLY,
+		(*VM).OP_DIVIDE,
+		(*VM).OP_EQUAL,
+		(*VM).OP_NOT_EQUAL,
+		(*VM).OP_GREATER,
+		(*VM).OP_LESS,
+		(*VM).OP_GREATER_EQUAL,
+		(*VM).OP_LESS_EQUAL,
+
+		// VARIABLE
+		(*VM).OP_DEFINE_GLOBAL,
+		(*VM).OP_GET_GLOBAL,
+		(*VM).OP_SET_GLOBAL,
+		(*VM).OP_GET_LOCAL,
+		(*VM).OP_SET_LOCAL,
+		(*VM).OP_GET_UPVALUE,
+		(*VM).OP_SET_UPVALUE,
+
+		// JUMP
+		(*VM).OP_JUMP,
+		(*VM).OP_JUMP_IF_FALSE,
+		(*VM).OP_LOOP,
+
+		// FUNCTION
+		(*VM).OP_CALL,
+		(*VM).OP_CLOSURE,
+		(*VM).OP_CLOSE_UPVALUE,
+
+		// CLASS
+		(*VM).OP_CLASS,
+		(*VM).OP_METHOD,
+		(*VM).OP_INHERIT,
+		(*VM).OP_GET_PROPERTY,
+		(*VM).OP_SET_PROPERTY,
+		(*VM).OP_GET_SUPER,
+
+		// SPECIAL
+		(*VM).OP_RETURN,
+		(*VM).OP_POP,
+		(*VM).OP_PRINT,
+
+		// EXCEPTION
+		(*VM).OP_THROW,
+		(*VM).OP_RETHROW,
+
+		// COLLECTION
+		(*VM).OP_LIST,
+		(*VM).OP_MAP,
+		(*VM).OP_GET_INDEX,
+		(*VM).OP_SET_INDEX,
+		(*VM).OP_ITER,
+		(*VM).OP_FOR_ITER,
+
+		// MODULE
+		(*VM).OP_IMPORT,
+	}
 }
 
 // ================================================================
@@ -133,9 +178,7 @@ func (vm *VM) OP_NEGATE() InterpretResult {
 	case float64:
 		vm.push(-v)
 	default:
-		log.Error("Operand must be a number", log.A("value", value))
-
-		return InterpretResultRuntimeError
+		return vm.runtimeError(diagnostic.CodeType, "operand must be a number")
 	}
 
 	return InterpretResultOK
@@ -154,56 +197,26 @@ func (vm *VM) OP_NOT() InterpretResult {
 // ================================================================
 
 func (vm *VM) OP_ADD() InterpretResult {
-	b := vm.pop()
-	a := vm.pop()
-
-	switch a.(type) {
-	case int64:
-		switch v := b.(type) {
-		case int64:
-			vm.push(a.(int64) + v)
-		case float64:
-			vm.push(float64(a.(int64)) + v)
-		case string:
-			vm.push(fmt.Sprintf("%d%s", a.(int64), v))
-		default:
-			log.Error("Operand must be a number", log.A("a", a), log.A("b", b))
-
-			return InterpretResultRuntimeError
-		}
-	case float64:
-		switch v := b.(type) {
-		case int64:
-			vm.push(a.(float64) + float64(v))
-		case float64:
-			vm.push(a.(float64) + v)
-		case string:
-			vm.push(fmt.Sprintf("%f%s", a.(float64), v))
-		default:
-			log.Error("Operand must be a number", log.A("a", a), log.A("b", b))
-
-			return InterpretResultRuntimeError
+	if as, ok := vm.peek(1).(string); ok {
+		bs, ok := vm.peek(0).(string)
+		if !ok {
+			return vm.runtimeError(diagnostic.CodeType, "can only concatenate string to string")
 		}
-	case string:
-		switch v := b.(type) {
-		case int64:
-			vm.push(fmt.Sprintf("%s%d", a.(string), v))
-		case float64:
-			vm.push(fmt.Sprintf("%s%f", a.(string), v))
-		case string:
-			vm.push(a.(string) + v)
-		default:
-			log.Error("Operand must be a number or string", log.A("a", a), log.A("b", b))
-
-			return InterpretResultRuntimeError
-		}
-	default:
-		log.Error("Operand must be a number or string", log.A("a", a), log.A("b", b))
 
-		return InterpretResultRuntimeError
+		vm.pop()
+		vm.pop()
+		vm.push(as + bs)
+
+		return InterpretResultOK
 	}
 
-	return InterpretResultOK
+	return vm._binary(
+		func(a int64, b int64) any {
+			return a + b
+		}, func(a float64, b float64) any {
+			return a + b
+		},
+	)
 }
 
 func (vm *VM) OP_SUBTRACT() InterpretResult {
@@ -229,11 +242,7 @@ func (vm *VM) OP_MULTIPLY() InterpretResult {
 func (vm *VM) OP_DIVIDE() InterpretResult {
 	return vm._binary(
 		func(a int64, b int64) any {
-			if b == 0 {
-				return float64(a) / float64(b)
-			} else {
-				return a / b
-			}
+			return float64(a) / float64(b)
 		}, func(a float64, b float64) any {
 			return a / b
 		},
@@ -327,9 +336,7 @@ func (vm *VM) _binary(
 		}
 	}
 
-	log.Error("Operand must be a number", log.A("a", a), log.A("b", b))
-
-	return InterpretResultRuntimeError
+	return vm.runtimeError(diagnostic.CodeType, "operand must be a int or float")
 }
 
 // ================================================================
@@ -340,7 +347,7 @@ func (vm *VM) OP_DEFINE_GLOBAL() InterpretResult {
 	name := vm.getConstant()
 
 	value := vm.pop()
-	vm.globals[name.(string)] = value
+	vm.globals()[name.(string)] = value
 
 	return InterpretResultOK
 }
@@ -348,29 +355,240 @@ func (vm *VM) OP_DEFINE_GLOBAL() InterpretResult {
 func (vm *VM) OP_GET_GLOBAL() InterpretResult {
 	name := vm.getConstant()
 
-	if value, ok := vm.globals[name.(string)]; ok {
+	if value, ok := vm.globals()[name.(string)]; ok {
 		vm.push(value)
 
 		return InterpretResultOK
 	}
 
-	log.Error("Undefined variable", log.A("name", name))
-
-	return InterpretResultRuntimeError
+	return vm.runtimeError(diagnostic.CodeUndefined, "undefined variable: %s", name)
 }
 
 func (vm *VM) OP_SET_GLOBAL() InterpretResult {
 	name := vm.getConstant()
-	if _, ok := vm.globals[name.(string)]; ok {
-		value := vm.pop()
-		vm.globals[name.(string)] = value
+	if _, ok := vm.globals()[name.(string)]; ok {
+		// assignment is an expression; its value stays on the stack
+		vm.globals()[name.(string)] = vm.peek(0)
 
 		return InterpretResultOK
 	}
 
-	log.Error("Undefined variable", log.A("name", name))
+	return vm.runtimeError(diagnostic.CodeUndefined, "cannot assign to undefined variable: %s", name)
+}
+
+func (vm *VM) OP_GET_LOCAL() InterpretResult {
+	slot := vm.getOperand()
+
+	vm.push(vm.stack[vm.frame.slots+int(slot)])
+
+	return InterpretResultOK
+}
+
+func (vm *VM) OP_SET_LOCAL() InterpretResult {
+	slot := vm.getOperand()
+
+	vm.stack[vm.frame.slots+int(slot)] = vm.peek(0)
+
+	return InterpretResultOK
+}
+
+func (vm *VM) OP_GET_UPVALUE() InterpretResult {
+	index := vm.getOperand()
+
+	vm.push(vm.readUpvalue(vm.frame.closure.Upvalues[index]))
+
+	return InterpretResultOK
+}
+
+func (vm *VM) OP_SET_UPVALUE() InterpretResult {
+	index := vm.getOperand()
+
+	vm.writeUpvalue(vm.frame.closure.Upvalues[index], vm.peek(0))
+
+	return InterpretResultOK
+}
+
+// ================================================================
+// JUMP
+// ================================================================
+
+func (vm *VM) OP_JUMP() InterpretResult {
+	offset := vm.getOperand()
+	vm.frame.ip += int(offset)
+
+	return InterpretResultOK
+}
+
+// OP_JUMP_IF_FALSE leaves the condition on the stack; the compiler pops it on both branches.
+func (vm *VM) OP_JUMP_IF_FALSE() InterpretResult {
+	offset := vm.getOperand()
+
+	if !util.IsTruthy(vm.peek(0)) {
+		vm.frame.ip += int(offset)
+	}
+
+	return InterpretResultOK
+}
+
+func (vm *VM) OP_LOOP() InterpretResult {
+	offset := vm.getOperand()
+	vm.frame.ip -= int(offset)
+
+	return InterpretResultOK
+}
+
+// ================================================================
+// FUNCTION
+// ================================================================
+
+func (vm *VM) OP_CALL() InterpretResult {
+	argCount := int(vm.getOperand())
+
+	return vm.callValue(vm.peek(argCount), argCount)
+}
+
+func (vm *VM) OP_CLOSURE() InterpretResult {
+	function := vm.getConstant().(*bytecode.Function)
+	closure := vm.newClosure(function, vm.frame.closure.module)
+
+	// Push first so the closure stays rooted while its upvalues are allocated.
+	vm.push(closure)
+
+	for i, info := range function.Upvalues {
+		if info.IsLocal {
+			closure.Upvalues[i] = vm.captureUpvalue(vm.frame.slots + info.Index)
+		} else {
+			closure.Upvalues[i] = vm.frame.closure.Upvalues[info.Index]
+		}
+	}
+
+	return InterpretResultOK
+}
 
-	return InterpretResultRuntimeError
+func (vm *VM) OP_CLOSE_UPVALUE() InterpretResult {
+	vm.closeUpvalues(len(vm.stack) - 1)
+	vm.pop()
+
+	return InterpretResultOK
+}
+
+// ================================================================
+// CLASS
+// ================================================================
+
+func (vm *VM) OP_CLASS() InterpretResult {
+	name := vm.getConstant()
+
+	vm.push(vm.newClass(name.(string)))
+
+	return InterpretResultOK
+}
+
+// OP_METHOD adds the closure on top of the stack to the class below it.
+func (vm *VM) OP_METHOD() InterpretResult {
+	name := vm.getConstant()
+
+	method := vm.peek(0).(*Closure)
+	class := vm.peek(1).(*Class)
+	class.Methods[name.(string)] = method
+	vm.pop()
+
+	return InterpretResultOK
+}
+
+// OP_INHERIT copies the superclass methods down into the subclass on top of
+// the stack, before the subclass defines its own.
+func (vm *VM) OP_INHERIT() InterpretResult {
+	superclass, ok := vm.peek(1).(*Class)
+	if !ok {
+		return vm.runtimeError(diagnostic.CodeType, "superclass must be a class")
+	}
+
+	subclass := vm.peek(0).(*Class)
+	for name, method := range superclass.Methods {
+		subclass.Methods[name] = method
+	}
+	vm.pop()
+
+	return InterpretResultOK
+}
+
+func (vm *VM) OP_GET_PROPERTY() InterpretResult {
+	name := vm.getConstant().(string)
+
+	if module, ok := vm.peek(0).(*builtin.Module); ok {
+		value, ok := module.Member(name)
+		if !ok {
+			return vm.runtimeError(diagnostic.CodeUndefined, "undefined member of %s: %s", module, name)
+		}
+
+		if native, ok := value.(*builtin.Native); ok {
+			value = &NativeFunction{Native: native}
+		}
+
+		vm.pop()
+		vm.push(value)
+
+		return InterpretResultOK
+	}
+
+	if record, ok := vm.peek(0).(builtin.Record); ok {
+		if value, ok := record.Field(name); ok {
+			vm.pop()
+			vm.push(value)
+
+			return InterpretResultOK
+		}
+	}
+
+	if object, ok := vm.peek(0).(builtin.Object); ok {
+		method, ok := object.Method(name)
+		if !ok {
+			return vm.runtimeError(diagnostic.CodeUndefined, "undefined %s method: %s", object.TypeName(), name)
+		}
+
+		vm.pop()
+		vm.push(&NativeFunction{Native: method})
+
+		return InterpretResultOK
+	}
+
+	instance, ok := vm.peek(0).(*Instance)
+	if !ok {
+		return vm.runtimeError(diagnostic.CodeType, "only instances have properties")
+	}
+
+	if value, ok := instance.Fields[name]; ok {
+		vm.pop()
+		vm.push(value)
+
+		return InterpretResultOK
+	}
+
+	return vm.bindMethod(instance.Class, name)
+}
+
+// OP_SET_PROPERTY expects the object on top of the value being assigned and
+// leaves the value as the result of the expression.
+func (vm *VM) OP_SET_PROPERTY() InterpretResult {
+	name := vm.getConstant().(string)
+
+	instance, ok := vm.pop().(*Instance)
+	if !ok {
+		return vm.runtimeError(diagnostic.CodeType, "only instances have fields")
+	}
+
+	instance.Fields[name] = vm.peek(0)
+
+	return InterpretResultOK
+}
+
+func (vm *VM) OP_GET_SUPER() InterpretResult {
+	name := vm.getConstant().(string)
+
+	superclass := vm.pop().(*Class)
+
+	return vm.bindMethod(superclass, name)
 }
 
 // ================================================================
@@ -378,6 +596,22 @@ func (vm *VM) OP_SET_GLOBAL() InterpretResult {
 // ================================================================
 
 func (vm *VM) OP_RETURN() InterpretResult {
+	result := vm.pop()
+	frame := vm.frame
+
+	vm.closeUpvalues(frame.slots)
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.stack = vm.stack[:frame.slots]
+
+	if len(vm.frames) == 0 {
+		vm.frame = nil
+
+		return InterpretResultOK
+	}
+
+	vm.frame = vm.frames[len(vm.frames)-1]
+	vm.push(result)
+
 	return InterpretResultOK
 }
 
@@ -389,7 +623,188 @@ func (vm *VM) OP_POP() InterpretResult {
 
 func (vm *VM) OP_PRINT() InterpretResult {
 	value := vm.pop()
-	fmt.Println(value)
+	fmt.Fprintln(vm.stdout, value)
+
+	return InterpretResultOK
+}
+
+// ================================================================
+// EXCEPTION
+// ================================================================
+
+func (vm *VM) OP_THROW() InterpretResult {
+	value := vm.pop()
+
+	err := &RuntimeError{
+		Message: "uncaught exception: " + vm.describeThrown(value),
+		Code:    diagnostic.CodeUncaught,
+		Trace:   vm.stackTrace(),
+		value:   value,
+	}
+
+	return vm.throw(err)
+}
+
+// OP_RETHROW ends the finally block run for an error and raises the error
+// its handler received again, keeping the original message and trace.
+func (vm *VM) OP_RETHROW() InterpretResult {
+	err, ok := vm.pop().(*RuntimeError)
+	if !ok {
+		return vm.runtimeError(diagnostic.CodeRuntime, "no pending error to rethrow")
+	}
+
+	return vm.throw(err)
+}
+
+// ================================================================
+// COLLECTION
+// ================================================================
+
+// OP_LIST replaces its operand's count of values on top of the stack with a
+// list of them, in the order they were pushed.
+func (vm *VM) OP_LIST() InterpretResult {
+	count := int(vm.getOperand())
+
+	elements := make([]any, count)
+	for i, value := range vm.stack[len(vm.stack)-count:] {
+		elements[i] = value
+	}
+
+	vm.stack = vm.stack[:len(vm.stack)-count]
+	vm.push(builtin.NewList(elements))
+
+	return InterpretResultOK
+}
+
+// OP_MAP replaces its operand's count of key and value pairs on top of the
+// stack with a map of them.
+func (vm *VM) OP_MAP() InterpretResult {
+	count := int(vm.getOperand())
+	entries := vm.stack[len(vm.stack)-2*count:]
+
+	m := builtin.NewMap()
+	for i := 0; i < len(entries); i += 2 {
+		if err := m.Set(entries[i], entries[i+1]); err != nil {
+			return vm.nativeError(err)
+		}
+	}
+
+	vm.stack = vm.stack[:len(vm.stack)-2*count]
+	vm.push(m)
+
+	return InterpretResultOK
+}
+
+// OP_GET_INDEX expects the object below the index.
+func (vm *VM) OP_GET_INDEX() InterpretResult {
+	value, err := builtin.GetIndex(vm.peek(1), vm.peek(0))
+	if err != nil {
+		return vm.nativeError(err)
+	}
+
+	vm.pop()
+	vm.pop()
+	vm.push(value)
+
+	return InterpretResultOK
+}
+
+// OP_SET_INDEX expects the value being assigned, then the object, then the
+// index, and leaves the value as the result of the expression.
+func (vm *VM) OP_SET_INDEX() InterpretResult {
+	if err := builtin.SetIndex(vm.peek(1), vm.peek(0), vm.peek(2)); err != nil {
+		return vm.nativeError(err)
+	}
+
+	vm.pop()
+	vm.pop()
+
+	return InterpretResultOK
+}
+
+// OP_ITER replaces the value on top of the stack with an iterator over it.
+func (vm *VM) OP_ITER() InterpretResult {
+	results := len(vm.nativeResults)
+	iterator, err := builtin.Iterate(vm, vm.peek(0))
+	if err != nil {
+		vm.nativeResults = vm.nativeResults[:results]
+
+		return vm.nativeError(err)
+	}
+
+	vm.pop()
+	vm.push(iterator)
+	vm.nativeResults = vm.nativeResults[:results]
+
+	return InterpretResultOK
+}
+
+// OP_FOR_ITER pushes the next value of the iterator on top of the stack, or
+// jumps by its operand once the iterator is done.
+func (vm *VM) OP_FOR_ITER() InterpretResult {
+	offset := vm.getOperand()
+
+	results := len(vm.nativeResults)
+	value, ok, err := vm.peek(0).(*builtin.Iterator).Next(vm)
+	vm.nativeResults = vm.nativeResults[:results]
+
+	if err != nil {
+		return vm.nativeError(err)
+	}
+
+	if !ok {
+		vm.frame.ip += int(offset)
+
+		return InterpretResultOK
+	}
+
+	vm.push(value)
+
+	return InterpretResultOK
+}
+
+// ================================================================
+// MODULE
+// ================================================================
+
+// OP_IMPORT pushes the module named by its operand, running the module's
+// script the first time it is imported.
+func (vm *VM) OP_IMPORT() InterpretResult {
+	path := vm.getConstant().(string)
+
+	if module, ok := builtin.StdModule(path); ok {
+		vm.push(module)
+
+		return InterpretResultOK
+	}
+
+	if vm.loader == nil {
+		return vm.runtimeError(diagnostic.CodeImport, "cannot find module %q", path)
+	}
+
+	file, chunk, err := vm.loader(path, vm.frame.closure.module.file)
+	if err != nil {
+		return vm.raise(&RuntimeError{Message: err.Error(), Code: diagnostic.CodeImport, cause: err})
+	}
+
+	if module, ok := vm.imports.Loaded(file); ok {
+		vm.push(module)
+
+		return InterpretResultOK
+	}
+
+	if err := vm.imports.Begin(file); err != nil {
+		return vm.nativeError(err)
+	}
+
+	module, rtErr := vm.runModule(file, chunk)
+	vm.imports.End(file, module)
+
+	if rtErr != nil {
+		return vm.throw(rtErr)
+	}
+
+	vm.push(module)
 
 	return InterpretResultOK
 }
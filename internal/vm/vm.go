@@ -1,65 +1,166 @@
 package vm
 
 import (
+	"bufio"
+	"fmt"
+	"internal/builtin"
 	"internal/bytecode"
+	"internal/diagnostic"
 	"internal/util/log"
+	"io"
+	"os"
 )
 
-type VM struct {
-	chunk   *bytecode.Chunk
+// FramesMax bounds the call depth; deeper calls fail with a stack overflow.
+// The tree-walking interpreter stops at the same depth.
+const FramesMax = builtin.MaxCallDepth
+
+// CallFrame is one active function call. slots is the stack index of the
+// frame's slot 0, which holds the called closure.
+type CallFrame struct {
+	closure *Closure
 	ip      int
-	stack   []bytecode.Value
-	globals map[string]bytecode.Value
-	objects *ObjectList
+	slots   int
+}
+
+type VM struct {
+	frames       []*CallFrame
+	frame        *CallFrame
+	stack        []bytecode.Value
+	openUpvalues *Upvalue
+	script       *module   // the globals of the script being run
+	modules      []*module // the globals of every script it imported
+	objects      *ObjectList
+	gc           gcState
+	err          *RuntimeError
+	errorClass   *Class // created by the first runtime error
+
+	// base is how many frames belong to the run loops outside the current
+	// one; Call runs a nested loop while a native waits for a callback.
+	base int
+	// nativeResults keeps the callback results a native is still holding
+	// reachable until the native returns.
+	nativeResults []bytecode.Value
+
+	loader  Loader
+	imports *builtin.Imports
+
+	stdout io.Writer
+	stdin  *bufio.Reader
 }
 
 func NewVM() *VM {
 	return &VM{
-		globals: make(map[string]bytecode.Value),
+		script:  newModule(""),
 		objects: NewObjectList(),
+		gc:      newGCState(),
+		imports: builtin.NewImports(),
+		stdout:  os.Stdout,
+		stdin:   bufio.NewReader(os.Stdin),
 	}
 }
 
+// SetOutput redirects everything the program prints.
+func (vm *VM) SetOutput(w io.Writer) {
+	vm.stdout = w
+}
+
+// SetInput replaces the reader the program reads from.
+func (vm *VM) SetInput(r io.Reader) {
+	vm.stdin = bufio.NewReader(r)
+}
+
+// Stdout and Stdin make the VM a builtin.Host.
+func (vm *VM) Stdout() io.Writer {
+	return vm.stdout
+}
+
+func (vm *VM) Stdin() *bufio.Reader {
+	return vm.stdin
+}
+
 func (vm *VM) Free() {
-	if vm.chunk != nil {
-		vm.chunk.Clear()
-		vm.chunk = nil
-	}
+	vm.resetStack()
+	vm.script = newModule("")
+	vm.modules = nil
+	vm.imports = builtin.NewImports()
+	vm.objects.Clear()
+	vm.gc.bytesAllocated = 0
+	vm.gc.nextGC = vm.gc.threshold
+	vm.errorClass = nil
+}
 
-	vm.ip = 0
+func (vm *VM) resetStack() {
+	vm.frames = vm.frames[:0]
+	vm.frame = nil
 	vm.stack = vm.stack[:0]
-	vm.globals = make(map[string]bytecode.Value)
-	vm.objects = NewObjectList()
+	vm.openUpvalues = nil
+	vm.base = 0
+	vm.nativeResults = vm.nativeResults[:0]
 }
 
+// Interpret runs chunk as the top-level script. Globals survive between
+// calls, which is what the REPL relies on.
 func (vm *VM) Interpret(chunk *bytecode.Chunk) InterpretResult {
-	vm.chunk = chunk
-	vm.ip = 0
+	vm.resetStack()
+	vm.err = nil
+
+	script := vm.newClosure(&bytecode.Function{Chunk: chunk}, vm.script)
+	vm.push(script)
+
+	if result := vm.call(script, 0); result != InterpretResultOK {
+		return result
+	}
 
 	return vm.run()
 }
 
+// InterpretDecoded is Interpret for a chunk read from a .hoc file. Verify
+// does not check stack discipline, so a crafted file can pass it and still
+// reach past the stack, such as reading local slot 50 of a two-slot frame.
+// The panic that causes is reported as a runtime error instead of crashing
+// the host.
+func (vm *VM) InterpretDecoded(chunk *bytecode.Chunk) (result InterpretResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			vm.err = &RuntimeError{
+				Message: fmt.Sprintf("invalid bytecode: %v", r),
+				Code:    diagnostic.CodeRuntime,
+			}
+			log.Debug("Runtime error", log.E(vm.err))
+
+			result = InterpretResultRuntimeError
+		}
+	}()
+
+	return vm.Interpret(chunk)
+}
+
+func (vm *VM) chunk() *bytecode.Chunk {
+	return vm.frame.closure.Function.Chunk
+}
+
 func (vm *VM) peekOp() bytecode.OpCode {
-	return vm.chunk.GetOperator(vm.ip)
+	return vm.chunk().GetOperator(vm.frame.ip)
 }
 
 func (vm *VM) getOp() bytecode.OpCode {
 	op := vm.peekOp()
-	vm.ip++
+	vm.frame.ip++
 
 	return op
 }
 
 func (vm *VM) peekOperand() int64 {
-	v, _ := vm.chunk.GetOperand(vm.ip)
+	v, _ := vm.chunk().GetOperand(vm.frame.ip)
 
 	return v
 }
 
 func (vm *VM) getOperand() int64 {
-	v, n := vm.chunk.GetOperand(vm.ip)
+	v, n := vm.chunk().GetOperand(vm.frame.ip)
 
-	vm.ip += n
+	vm.frame.ip += n
 
 	return v
 }
@@ -67,13 +168,13 @@ func (vm *VM) getOperand() int64 {
 func (vm *VM) peekConstant() bytecode.Value {
 	constIndex := vm.peekOperand()
 
-	return vm.chunk.GetConstant(constIndex)
+	return vm.chunk().GetConstant(constIndex)
 }
 
 func (vm *VM) getConstant() bytecode.Value {
 	constIndex := vm.getOperand()
 
-	return vm.chunk.GetConstant(constIndex)
+	return vm.chunk().GetConstant(constIndex)
 }
 
 func (vm *VM) push(value bytecode.Value) {
@@ -92,26 +193,239 @@ func (vm *VM) pop() bytecode.Value {
 	return value
 }
 
+func (vm *VM) peek(distance int) bytecode.Value {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// callValue calls the value sitting below its argCount arguments on the stack.
+func (vm *VM) callValue(callee bytecode.Value, argCount int) InterpretResult {
+	switch callee := callee.(type) {
+	case *Closure:
+		return vm.call(callee, argCount)
+
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.Receiver
+
+		return vm.call(callee.Method, argCount)
+
+	case *NativeFunction:
+		return vm.callNative(callee.Native, argCount)
+
+	case *Class:
+		vm.stack[len(vm.stack)-argCount-1] = vm.newInstance(callee)
+
+		if initializer, ok := callee.Methods["init"]; ok {
+			return vm.call(initializer, argCount)
+		}
+
+		if argCount != 0 {
+			return vm.runtimeError(diagnostic.CodeArity, "expected 0 arguments but got %d", argCount)
+		}
+
+		return InterpretResultOK
+	}
+
+	return vm.runtimeError(diagnostic.CodeType, "can only call functions and classes")
+}
+
+func (vm *VM) call(closure *Closure, argCount int) InterpretResult {
+	function := closure.Function
+
+	if argCount != function.Arity {
+		return vm.runtimeError(diagnostic.CodeArity, "expected %d arguments but got %d", function.Arity, argCount)
+	}
+
+	if len(vm.frames) >= FramesMax {
+		return vm.runtimeError(diagnostic.CodeStackOverflow, "stack overflow")
+	}
+
+	vm.frame = &CallFrame{
+		closure: closure,
+		slots:   len(vm.stack) - argCount - 1,
+	}
+	vm.frames = append(vm.frames, vm.frame)
+
+	return InterpretResultOK
+}
+
+// callNative runs a Go built-in and replaces the callee and its arguments
+// with the result.
+func (vm *VM) callNative(native *builtin.Native, argCount int) InterpretResult {
+	if argCount != native.Arity {
+		return vm.runtimeError(diagnostic.CodeArity, "expected %d arguments but got %d", native.Arity, argCount)
+	}
+
+	arguments := make([]any, argCount)
+	for i, arg := range vm.stack[len(vm.stack)-argCount:] {
+		arguments[i] = arg
+	}
+
+	results := len(vm.nativeResults)
+	result, err := native.Fn(vm, arguments)
+	vm.nativeResults = vm.nativeResults[:results]
+
+	if err != nil {
+		return vm.nativeError(err)
+	}
+
+	vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+	vm.push(result)
+
+	return InterpretResultOK
+}
+
+// nativeError raises an error returned by Go built-in code. An error raised
+// in a callback the built-in ran keeps unwinding from here.
+func (vm *VM) nativeError(err error) InterpretResult {
+	if rtErr, ok := err.(*RuntimeError); ok {
+		return vm.throw(rtErr)
+	}
+
+	return vm.runtimeError(builtin.CodeOf(err), "%s", err.Error())
+}
+
+// Method makes natives able to call methods of instances; see builtin.Host.
+func (vm *VM) Method(object any, name string) (any, bool) {
+	instance, ok := object.(*Instance)
+	if !ok {
+		return nil, false
+	}
+
+	method, ok := instance.Class.Methods[name]
+	if !ok {
+		return nil, false
+	}
+
+	return vm.newBoundMethod(instance, method), true
+}
+
+// Fields makes natives able to read the fields of instances; see builtin.Host.
+func (vm *VM) Fields(object any) (map[string]any, bool) {
+	instance, ok := object.(*Instance)
+	if !ok {
+		return nil, false
+	}
+
+	fields := make(map[string]any, len(instance.Fields))
+	for name, value := range instance.Fields {
+		fields[name] = value
+	}
+
+	return fields, true
+}
+
+// Call makes the VM a builtin.Host that can run callbacks. It calls callee
+// and runs a nested loop until that call returns; an error the callback does
+// not catch is returned to the native instead of unwinding past it.
+func (vm *VM) Call(callee any, arguments ...any) (any, error) {
+	outer := vm.base
+	vm.base = len(vm.frames)
+	defer func() { vm.base = outer }()
+
+	height := len(vm.stack)
+	vm.push(callee)
+	for _, arg := range arguments {
+		vm.push(arg)
+	}
+
+	if vm.callValue(callee, len(arguments)) != InterpretResultOK || vm.run() != InterpretResultOK {
+		vm.stack = vm.stack[:height]
+
+		return nil, vm.err
+	}
+
+	result := vm.pop()
+	vm.nativeResults = append(vm.nativeResults, result)
+
+	return result, nil
+}
+
+// bindMethod replaces the instance on top of the stack with its method name
+// bound to it.
+func (vm *VM) bindMethod(class *Class, name string) InterpretResult {
+	method, ok := class.Methods[name]
+	if !ok {
+		return vm.runtimeError(diagnostic.CodeUndefined, "undefined property: %s", name)
+	}
+
+	bound := vm.newBoundMethod(vm.peek(0), method)
+	vm.pop()
+	vm.push(bound)
+
+	return InterpretResultOK
+}
+
+// captureUpvalue returns the open upvalue for the stack slot at location,
+// creating it if no closure has captured that slot yet.
+func (vm *VM) captureUpvalue(location int) *Upvalue {
+	var prev *Upvalue
+	upvalue := vm.openUpvalues
+
+	for upvalue != nil && upvalue.location > location {
+		prev = upvalue
+		upvalue = upvalue.next
+	}
+
+	if upvalue != nil && upvalue.location == location {
+		return upvalue
+	}
+
+	created := vm.newUpvalue(location, upvalue)
+
+	if prev == nil {
+		vm.openUpvalues = created
+	} else {
+		prev.next = created
+	}
+
+	return created
+}
+
+// closeUpvalues moves every captured stack slot at or above last into its upvalue.
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.location >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = vm.stack[upvalue.location]
+		upvalue.isClosed = true
+		vm.openUpvalues = upvalue.next
+		upvalue.next = nil
+	}
+}
+
+func (vm *VM) readUpvalue(upvalue *Upvalue) bytecode.Value {
+	if upvalue.isClosed {
+		return upvalue.closed
+	}
+
+	return vm.stack[upvalue.location]
+}
+
+func (vm *VM) writeUpvalue(upvalue *Upvalue, value bytecode.Value) {
+	if upvalue.isClosed {
+		upvalue.closed = value
+	} else {
+		vm.stack[upvalue.location] = value
+	}
+}
+
 func (vm *VM) run() InterpretResult {
-	for vm.ip < vm.chunk.Size() {
+	for len(vm.frames) > vm.base {
 		instruction := vm.getOp()
 
 		log.DebugIfEnabled("VM run", func() []log.Field {
 			return []log.Field{
-				log.I("ip", vm.ip-1),
+				log.S("function", vm.frame.closure.String()),
+				log.I("ip", vm.frame.ip-1),
 				log.A("instruction", instruction),
 				log.A("stack", vm.stack),
 			}
 		})
 
-		fn := OP_FUNCS[instruction]
-		if fn == nil {
-			log.Error("Unknown opcode", log.A("opcode", instruction))
-
-			return InterpretResultRuntimeError
+		if int(instruction) >= len(OP_FUNCS) {
+			return vm.runtimeError(diagnostic.CodeRuntime, "unknown opcode %d", instruction)
 		}
 
-		result := fn(vm)
+		result := OP_FUNCS[instruction](vm)
 		if result != InterpretResultOK {
 			return result
 		}
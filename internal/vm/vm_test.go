@@ -0,0 +1,599 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"internal/builtin"
+	"internal/bytecode"
+	"internal/codegen"
+	"internal/diagnostic"
+	"internal/parser"
+	"internal/scanner"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func runSource(t *testing.T, source string) (string, InterpretResult) {
+	t.Helper()
+
+	return runSourceOn(t, NewVM(), source)
+}
+
+func compileSource(t *testing.T, source string) *bytecode.Chunk {
+	t.Helper()
+
+	tokens, errs := scanner.NewScanner(source).ScanTokens()
+	if len(errs) > 0 {
+		t.Fatalf("scan: %v", errs)
+	}
+
+	statements, errs := parser.NewParser(tokens).Parse()
+	if len(errs) > 0 {
+		t.Fatalf("parse: %v", errs)
+	}
+
+	ch := bytecode.NewChunk()
+	if err := codegen.NewCodeGenerator(codegen.NewChunkEmitter(ch)).Generate(statements); err != nil {
+		t.Fatalf("codegen: %v", err)
+	}
+
+	return ch
+}
+
+func runSourceOn(t *testing.T, vm *VM, source string) (string, InterpretResult) {
+	t.Helper()
+
+	return runChunkOn(vm, compileSource(t, source))
+}
+
+func runChunkOn(vm *VM, ch *bytecode.Chunk) (string, InterpretResult) {
+	var out bytes.Buffer
+	vm.SetOutput(&out)
+	result := vm.Interpret(ch)
+
+	return out.String(), result
+}
+
+func expectOutput(t *testing.T, source string, want ...string) {
+	t.Helper()
+
+	got, result := runSource(t, source)
+	if result != InterpretResultOK {
+		t.Fatalf("result: got %v want OK (output %q)", result, got)
+	}
+
+	if w := strings.Join(want, "\n") + "\n"; got != w {
+		t.Fatalf("output:\ngot  %q\nwant %q", got, w)
+	}
+}
+
+func TestVM_ControlFlow(t *testing.T) {
+	expectOutput(t, `
+		var s = 0;
+		for (var i = 0; i < 10; i = i + 1) {
+			if (i == 2) continue;
+			if (i == 6) break;
+			s = s + i;
+		}
+		print s;
+		print nil or "default";
+		print 1 < 2 ? "yes" : "no";
+	`, "13", "default", "yes")
+}
+
+func TestVM_BlockScopesAndShadowing(t *testing.T) {
+	expectOutput(t, `
+		var a = "global";
+		{
+			var a = "outer";
+			{
+				var a = "inner";
+				print a;
+				a = "assigned";
+				print a;
+			}
+			print a;
+		}
+		print a;
+	`, "inner", "assigned", "outer", "global")
+}
+
+func TestVM_FunctionsAndRecursion(t *testing.T) {
+	expectOutput(t, `
+		fun fib(n) {
+			if (n <= 1) return n;
+			return fib(n - 1) + fib(n - 2);
+		}
+		fun noReturn() { var x = 1; }
+
+		print fib(15);
+		print noReturn();
+		print fib;
+	`, "610", "<nil>", "<fn fib>")
+}
+
+func TestVM_ArityMismatchIsRuntimeError(t *testing.T) {
+	if _, result := runSource(t, "fun f(a) { return a; } f(1, 2);"); result != InterpretResultRuntimeError {
+		t.Fatalf("result: got %v want runtime error", result)
+	}
+}
+
+func TestVM_ClosuresCaptureAndCloseUpvalues(t *testing.T) {
+	expectOutput(t, `
+		fun makeCounter() {
+			var c = 0;
+			fun inc() { c = c + 1; return c; }
+			return inc;
+		}
+		var a = makeCounter();
+		var b = makeCounter();
+		print a();
+		print a();
+		print b();
+
+		fun each(n, cb) { for (var i = 0; i < n; i = i + 1) cb(i); }
+		{
+			var sum = 0;
+			fun add(i) { sum = sum + i; }
+			each(4, add);
+			print sum;
+		}
+	`, "1", "2", "1", "6")
+}
+
+func TestVM_ClassesInheritanceAndSuper(t *testing.T) {
+	expectOutput(t, `
+		class Animal {
+			init(name) { this.name = name; }
+			speak() { return this.name + " makes a sound"; }
+		}
+		class Dog < Animal {
+			init(name) { super.init(name); this.tricks = 0; }
+			speak() { return super.speak() + " (woof)"; }
+		}
+
+		var d = Dog("Rex");
+		print d.speak();
+		var bound = d.speak;
+		d.name = "Max";
+		print bound();
+		print d.init("Bob") == d;
+		print d;
+	`, "Rex makes a sound (woof)", "Max makes a sound (woof)", "true", "Dog instance")
+}
+
+func TestVM_NativeFunctions(t *testing.T) {
+	expectOutput(t, `
+		print(str(1) + "2");
+		print int("41") + 1;
+		print strlen("héllo");
+		print substring("holang", 2, 6);
+		print clock;
+	`, "12", "42", "5", "lang", "<native fn clock>")
+
+	if _, result := runSource(t, `substring("x", 0, 5);`); result != InterpretResultRuntimeError {
+		t.Fatalf("result: got %v want runtime error", result)
+	}
+}
+
+func TestVM_GCStressKeepsReachableObjects(t *testing.T) {
+	vm := NewVM()
+	vm.SetGCStress(true)
+
+	got, result := runSourceOn(t, vm, `
+		class Node {
+			init(value, next) { this.value = value; this.next = next; }
+			sum() {
+				if (this.next == nil) return this.value;
+				return this.value + this.next.sum();
+			}
+		}
+		fun makeAdder(n) { fun add(x) { return x + n; } return add; }
+
+		var list = nil;
+		for (var i = 1; i <= 10; i = i + 1) list = Node(i, list);
+		var add = makeAdder(list.sum());
+		var sum = list.sum;
+		print add(1);
+		print sum();
+	`)
+	if result != InterpretResultOK || got != "56\n55\n" {
+		t.Fatalf("got %q (%v)", got, result)
+	}
+
+	if stats := vm.GCStats(); stats.Collections < stats.ObjectsAllocated || stats.ObjectsFreed == 0 {
+		t.Fatalf("stress mode should collect on every allocation: %+v", stats)
+	}
+}
+
+func TestVM_GCFreesUnreachableObjects(t *testing.T) {
+	vm := NewVM()
+	vm.SetGCThreshold(1024)
+
+	_, result := runSourceOn(t, vm, `
+		class Particle { init(x) { this.x = x; } }
+		var kept = Particle(-1);
+		for (var i = 0; i < 5000; i = i + 1) {
+			var p = Particle(i);
+		}
+		print kept.x;
+	`)
+	if result != InterpretResultOK {
+		t.Fatalf("result: got %v want OK", result)
+	}
+
+	stats := vm.GCStats()
+	if stats.Collections == 0 || stats.LiveObjects > 100 {
+		t.Fatalf("heap was not collected: %+v", stats)
+	}
+
+	vm.CollectGarbage()
+	if live := vm.GCStats().LiveObjects; live != 3 { // Particle, its init closure and kept
+		t.Fatalf("live objects after full collection: got %d want 3", live)
+	}
+}
+
+func TestVM_GCTracesReceiversOfBuiltinMethods(t *testing.T) {
+	vm := NewVM()
+
+	_, result := runSourceOn(t, vm, `
+		class Box { init(v) { this.v = v; } }
+		var p;
+		{ var xs = [Box(1)]; p = xs.map; }
+	`)
+	if result != InterpretResultOK {
+		t.Fatalf("result: got %v want OK", result)
+	}
+
+	vm.CollectGarbage()
+	if live := vm.GCStats().LiveObjects; live != 3 { // Box, its init closure and the box in the list
+		t.Fatalf("live objects after full collection: got %d want 3", live)
+	}
+
+	if got, result := runSourceOn(t, vm, "print p((b) => b.v);"); result != InterpretResultOK || got != "[1]\n" {
+		t.Fatalf("got %q (%v)", got, result)
+	}
+}
+
+func TestVM_RunsDecodedBytecode(t *testing.T) {
+	source := `
+		class Greeter {
+			init(greeting) { this.greeting = greeting; }
+			greet(name) { return this.greeting + ", " + name; }
+		}
+		fun counter() { var n = 0; fun next() { n = n + 1; return n; } return next; }
+		var next = counter();
+		next();
+		print Greeter("hi").greet("holang");
+		print next() * 1.5;
+	`
+
+	data, err := bytecode.Encode(compileSource(t, source))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	ch, err := bytecode.Decode(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if got, result := runChunkOn(NewVM(), ch); result != InterpretResultOK || got != "hi, holang\n3\n" {
+		t.Fatalf("got %q (%v)", got, result)
+	}
+}
+
+func TestVM_DecodedBytecodeOutOfFrameIsRuntimeError(t *testing.T) {
+	data, err := bytecode.Encode(compileSource(t, "{ var a = 1; print a; }"))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	// read local slot 50 instead of 1 (operands are zigzag varints) and
+	// re-sign the file, which Verify cannot catch
+	at := bytes.Index(data, []byte{byte(bytecode.OP_GET_LOCAL), 2})
+	if at < 0 {
+		t.Fatal("no OP_GET_LOCAL 1 in the encoded chunk")
+	}
+
+	data[at+1] = 100
+	body := data[:len(data)-4]
+	data = binary.LittleEndian.AppendUint32(body, crc32.ChecksumIEEE(body))
+
+	ch, err := bytecode.Decode(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	vm := NewVM()
+	vm.SetOutput(io.Discard)
+	if result := vm.InterpretDecoded(ch); result != InterpretResultRuntimeError {
+		t.Fatalf("result: got %v want runtime error", result)
+	}
+
+	if msg := vm.LastError().Message; !strings.HasPrefix(msg, "invalid bytecode: ") {
+		t.Fatalf("message: %q", msg)
+	}
+}
+
+func TestVM_RuntimeErrorStackTrace(t *testing.T) {
+	vm := NewVM()
+
+	_, result := runSourceOn(t, vm, "fun inner() {\n  return 1 + nil;\n}\nfun outer() { return inner(); }\nouter();\n")
+	if result != InterpretResultRuntimeError {
+		t.Fatalf("result: got %v want runtime error", result)
+	}
+
+	err := vm.LastError()
+	want := []diagnostic.Frame{
+		{Function: "inner", Span: diagnostic.Span{Line: 2, Column: 10, Index: 23, Length: 7}},
+		{Function: "outer", Span: diagnostic.Span{Line: 4, Column: 22, Index: 55, Length: 7}},
+		{Function: "<script>", Span: diagnostic.Span{Line: 5, Column: 1, Index: 66, Length: 7}},
+	}
+	if err == nil || err.Message != "operand must be a int or float" || err.Code != diagnostic.CodeType || !reflect.DeepEqual(err.Trace, want) {
+		t.Fatalf("got %+v want trace %+v", err, want)
+	}
+}
+
+func TestVM_TryCatchFinally(t *testing.T) {
+	expectOutput(t, `
+		fun risky(n) {
+			if (n > 1) throw "too big";
+			return n;
+		}
+		for (var i = 0; i < 5; i = i + 1) {
+			try {
+				print risky(i);
+			} catch (e) {
+				print e;
+				break;
+			} finally {
+				print "finally";
+			}
+		}
+		try { var x = 1 + nil; } catch (e) { print e.message; print e.line; }
+		fun f() { try { return "try"; } finally { print "cleanup"; } }
+		print f();
+		try { try { throw 1; } finally { throw 2; } } catch (e) { print e; }
+	`, "0", "finally", "1", "finally", "too big", "finally",
+		"operand must be a int or float", "16", "cleanup", "try", "2")
+}
+
+func TestVM_UncaughtThrowIsRuntimeError(t *testing.T) {
+	vm := NewVM()
+
+	_, result := runSourceOn(t, vm, "fun f() { throw \"boom\"; }\ntry { f(); } finally { print 1; }\n")
+	if result != InterpretResultRuntimeError {
+		t.Fatalf("result: got %v want runtime error", result)
+	}
+
+	err := vm.LastError()
+	if err == nil || err.Code != diagnostic.CodeUncaught || err.Message != "uncaught exception: boom" || len(err.Trace) != 2 {
+		t.Fatalf("got %+v", err)
+	}
+}
+
+func TestVM_ListsAndCallbacks(t *testing.T) {
+	vm := NewVM()
+	vm.SetGCStress(true)
+
+	got, result := runSourceOn(t, vm, `
+		class Box { init(v) { this.v = v; } }
+		fun box(x) { return Box(x * 10); }
+		fun odd(b) { return b.v / 10 != 2; }
+		fun unbox(b) { return b.v; }
+		fun fail(x) { throw "bad " + str(x); }
+
+		var xs = [3, 1, 2];
+		xs.push(-4);
+		xs[0] = xs[-1];
+		xs.sort();
+		print xs;
+		print xs.map(box).filter(odd).map(unbox).join("+");
+		try { xs.map(fail); } catch (e) { print e; }
+		try { print xs[9]; } catch (e) { print e.message; }
+	`)
+	want := "[-4, -4, 1, 2]\n-40+-40+10\nbad -4\nlist index out of range: 9\n"
+	if result != InterpretResultOK || got != want {
+		t.Fatalf("got %q (%v) want %q", got, result, want)
+	}
+}
+
+func TestVM_MapsKeepInsertionOrder(t *testing.T) {
+	expectOutput(t, `
+		var m = {"hp": 10, "atk": 3};
+		m["def"] = 1;
+		m["hp"] = m["hp"] + 1;
+		m.delete("atk");
+		m[2.0] = "two";
+		print m;
+		print m.keys();
+		print m[2] + str(m.has("atk"));
+	`, `{"hp": 11, "def": 1, 2: "two"}`, `["hp", "def", 2]`, "twofalse")
+}
+
+func TestVM_ForInLoops(t *testing.T) {
+	expectOutput(t, `
+		class Twice {
+			init(v) { this.v = v; this.n = 0; }
+			iter() { return this; }
+			next() {
+				if (this.n == 2) return nil;
+				this.n = this.n + 1;
+				return this.v;
+			}
+		}
+		var out = [];
+		for (var x in [1, 2]) out.push(x);
+		for (var k in {"a": 1}) out.push(k);
+		for (var c in "hé") out.push(c);
+		for (var i in range(10, 0, -4)) {
+			if (i == 6) continue;
+			out.push(i);
+		}
+		for (var t in Twice("t")) out.push(t);
+		var fs = [];
+		for (var i in range(0, 3, 1)) { fun f() { return i; } fs.push(f); }
+		for (var f in fs) { if (f() == 2) break; out.push(f()); }
+		print out.join(" ");
+	`, "1 2 a h é 10 2 t t 0 1")
+}
+
+func TestVM_Lambdas(t *testing.T) {
+	expectOutput(t, `
+		fun counter() {
+			var n = 0;
+			return () => n = n + 1;
+		}
+		var c = counter();
+		c();
+		var add = fun (a, b) { return a + b; };
+		var odd = [1, 2, 3].filter((x) => x != 2);
+		print str(c()) + " " + str(add(1, 2)) + " " + odd.join(",") + " " + str((1 + 2) * 3);
+	`, "2 3 1,3 9")
+}
+
+func TestVM_ImportsRunEachModuleOnceWithItsOwnGlobals(t *testing.T) {
+	modules := map[string]string{
+		"counter": `
+			var n = 0;
+			fun bump() { n = n + 1; return n; }
+			print "loaded";
+		`,
+		"a": `import "b";`,
+		"b": `import "a";`,
+	}
+
+	vm := NewVM()
+	vm.SetLoader(func(path string, from string) (string, *bytecode.Chunk, error) {
+		return path + ".holang", compileSource(t, modules[path]), nil
+	})
+
+	got, result := runSourceOn(t, vm, `
+		import "counter" as c;
+		from "counter" import bump;
+		var n = 100;
+		bump();
+		print c.bump() + n;
+		print c.n;
+	`)
+	if result != InterpretResultOK || got != "loaded\n102\n2\n" {
+		t.Fatalf("got %q (%v)", got, result)
+	}
+
+	_, result = runSourceOn(t, vm, `import "a";`)
+	if err := vm.LastError(); result != InterpretResultRuntimeError || err.Code != diagnostic.CodeImport ||
+		err.Message != "import cycle: a.holang -> b.holang -> a.holang" {
+		t.Fatalf("cycle: got %v %+v", result, err)
+	}
+}
+
+func TestVM_MathModuleKeepsIntsWhereItCan(t *testing.T) {
+	expectOutput(t, `
+		import "math";
+		print [math.floor(2.7), math.abs(-3), math.max(2, 7), math.clamp(15, 0, 10), math.pow(2, 10)];
+		print [math.sqrt(16), math.min(1, 2.5), math.pow(2, -1)];
+	`, "[2, 3, 7, 10, 1024]", "[4, 1, 0.5]")
+}
+
+func TestVM_StringsModuleCountsCharacters(t *testing.T) {
+	expectOutput(t, `
+		import "strings";
+		print strings.indexOf("호랭이 만세", "만세");
+		print strings.padLeft("호랭", 4, "*") + strings.upper(strings.trim(" ok "));
+		print strings.format("{}/{} {{}}", strings.split("a,b", ","));
+	`, "4", "**호랭OK", "a/b {}")
+}
+
+func TestVM_FsModuleIsSandboxed(t *testing.T) {
+	dir := t.TempDir()
+	if err := builtin.AllowRead(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := builtin.AllowWrite(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	expectOutput(t, strings.ReplaceAll(`
+		import "fs";
+		fs.writeFile("DIR/a.txt", "one\ntwo\n");
+		fs.appendFile("DIR/a.txt", "three");
+		var lines = [];
+		for (var line in fs.open("DIR/a.txt")) lines.push(line);
+		print lines;
+		print fs.listDir("DIR");
+		try { fs.readFile("DIR/../outside"); } catch (e) { print e.message; }
+	`, "DIR", dir),
+		`["one", "two", "three"]`,
+		`["a.txt"]`,
+		"readFile: access to "+dir+"/../outside denied; run with --allow-read=DIR to allow it")
+}
+
+func TestVM_JSONRoundTripsValuesAndInstances(t *testing.T) {
+	expectOutput(t, `
+		import "json";
+		class Save { init() { this.level = 3; this.ratio = 2.0; } }
+		var data = json.parse("{\"b\": [1, 1.5, null], \"a\": {\"save\": 0}}");
+		data["a"]["save"] = Save();
+		print json.stringify(data, nil);
+		data["b"].push(data);
+		try { json.stringify(data, 2); } catch (e) { print e.message; }
+	`, `{"b":[1,1.5,null],"a":{"save":{"level":3,"ratio":2.0}}}`,
+		"stringify: cannot write a structure that contains itself")
+}
+
+func TestVM_TimeModuleConvertsZonesAndAddsDurations(t *testing.T) {
+	expectOutput(t, `
+		import "time";
+		var d = time.parse("2024-03-10 01:30", "2006-01-02 15:04", "America/New_York");
+		print d.hour;
+		var later = d.add(time.hour);
+		print later.format(time.iso);
+		print later.sub(d) == time.duration("1h");
+		print d.inZone("Asia/Seoul").format("2006-01-02 15:04 MST");
+		var start = time.nanos();
+		print time.nanos() >= start;
+	`, "1", "2024-03-10T03:30:00-04:00", "true", "2024-03-10 15:30 KST", "true")
+}
+
+func TestVM_RegexModuleCountsCharactersAndCallsBack(t *testing.T) {
+	expectOutput(t, `
+		import "re";
+		var r = re.compile("(?P<key>[a-z]+)=(?P<value>\\d+)");
+		var m = r.find("가나 hp=10");
+		print m.start;
+		print m.named["value"];
+		print r.replace("a=1 b=2", "${value}:${key}");
+		print r.replace("a=1 b=2", (m) => m.groups[0] + "!");
+		print re.compile(",\\s*").split("a, b,c");
+	`, "3", "10", "1:a 2:b", "a! b!", `["a", "b", "c"]`)
+}
+
+func TestVM_OsModulePassesArgsAndExitCode(t *testing.T) {
+	builtin.SetArgs([]string{"level1", "--fast"})
+	defer builtin.SetArgs(nil)
+
+	exited := -1
+	builtin.SetExit(func(code int) { exited = code })
+	defer builtin.SetExit(os.Exit)
+
+	t.Setenv("HOLANG_VM_TEST", "on")
+
+	expectOutput(t, `
+		import "os";
+		print os.args;
+		print os.env("HOLANG_VM_TEST");
+		os.setEnv("HOLANG_VM_TEST", nil);
+		print os.env("HOLANG_VM_TEST");
+		os.exit(3);
+	`, `["level1", "--fast"]`, "on", "<nil>")
+
+	if exited != 3 {
+		t.Errorf("exit code = %d, want 3", exited)
+	}
+}